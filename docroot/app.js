@@ -5,6 +5,7 @@ const maxLabelLength = 25
 const labelYOffset = 30
 
 var color = d3.scaleOrdinal(d3.schemeCategory10)
+var linkColor = d3.scaleOrdinal(d3.schemeCategory20b)
 
 var app = new Vue({
     el: '#app',
@@ -14,6 +15,9 @@ var app = new Vue({
         main: {
             projects: [],
             graph: { "nodes": [], "links": [] },
+            warnings: [],
+            linkTypes: [],
+            hiddenLinkTypes: [],
             svg: {},
             width: 0,
             height: 0,
@@ -21,7 +25,8 @@ var app = new Vue({
             simulation: {},
             linkElements: [],
             nodeElements: [],
-            textElements: []
+            textElements: [],
+            eventSource: null
         },
         showReload: false,
         error: { message: '' },
@@ -91,29 +96,32 @@ var app = new Vue({
             this.screen = 'loading'
             let that = this
 
+            this.stopWatching()
+
             d3.selectAll("text").remove()
             d3.selectAll("line").remove()
             d3.selectAll("circle").remove()
 
-            d3.json("/api/graph/" + namespace, function(error, data) {
-                if (error) {
-                    that.showError(error)
-                    return
-                }
+            this.main.eventSource = new EventSource("/api/watch/" + namespace)
+
+            this.main.eventSource.addEventListener("graph", function(event) {
+                let data = JSON.parse(event.data)
 
                 if (data.error) {
+                    that.stopWatching()
                     that.showError(data.error)
                     return
                 }
 
                 if (data.nodes) {
-                    data.nodes.forEach(node => {
-                        if (node.object && node.object.metadata && node.object.metadata.managedFields)
-                            delete node.object.metadata.managedFields
-                    })
+                    data.nodes.forEach(node => that.stripNode(node))
                 }
-            
+
+                if (that.main.simulation.stop) that.main.simulation.stop()
+                d3.selectAll("g.everything").remove()
+
                 that.main.graph = data;
+                that.main.warnings = data.warnings || []
 
                 that.main.simulation = d3.forceSimulation()
                     .force("link", d3.forceLink().distance(linkLength).id(function (d) { // distance set length of links
@@ -136,6 +144,92 @@ var app = new Vue({
                 that.showReload = true
                 that.screen = 'main'
             })
+
+            // incremental updates sent after the initial graph, in batches
+            // of node-added, node-updated, node-removed, link-added,
+            // link-removed and warnings events
+            this.main.eventSource.addEventListener("changes", function(event) {
+                that.applyEvents(JSON.parse(event.data))
+            })
+
+            this.main.eventSource.onerror = function() {
+                // EventSource reconnects by itself and the server sends the
+                // full graph again when it does
+                if (that.main.eventSource.readyState === EventSource.CLOSED) {
+                    that.showError("lost connection to the server")
+                }
+            }
+        },
+
+        stopWatching: function() {
+            if (this.main.eventSource) {
+                this.main.eventSource.close()
+                this.main.eventSource = null
+            }
+        },
+
+        stripNode: function(node) {
+            if (node.object && node.object.metadata && node.object.metadata.managedFields)
+                delete node.object.metadata.managedFields
+        },
+
+        // patches the graph in place so that existing nodes keep their
+        // positions, and redraws it once for the whole batch
+        applyEvents: function(events) {
+            let changed = false
+            events.forEach(event => {
+                if (this.applyEvent(event)) changed = true
+            })
+            if (!changed) return
+
+            this.drawGraph()
+            this.main.simulation.nodes(this.main.graph.nodes)
+            this.main.simulation.force("link").links(this.main.graph.links)
+            this.main.simulation.alpha(0.3).restart()
+        },
+
+        // returns true if the nodes or links of the graph changed
+        applyEvent: function(event) {
+            let graph = this.main.graph
+            let linkId = function(end) {
+                return (typeof end === "object") ? end.id : end
+            }
+
+            switch (event.type) {
+            case "node-added":
+                this.stripNode(event.node)
+                graph.nodes.push(event.node)
+                break
+            case "node-updated":
+                this.stripNode(event.node)
+                let existing = graph.nodes.find(n => n.id === event.node.id)
+                if (!existing) {
+                    graph.nodes.push(event.node)
+                    break
+                }
+                existing.kind = event.node.kind
+                existing.name = event.node.name
+                existing.object = event.node.object
+                existing.status = event.node.status
+                existing.summary = event.node.summary
+                break
+            case "node-removed":
+                graph.nodes = graph.nodes.filter(n => n.id !== event.node.id)
+                graph.links = graph.links.filter(l => linkId(l.source) !== event.node.id && linkId(l.target) !== event.node.id)
+                break
+            case "link-added":
+                graph.links.push(event.link)
+                break
+            case "link-removed":
+                graph.links = graph.links.filter(l => linkId(l.source) !== event.link.source || linkId(l.target) !== event.link.target || l.type !== event.link.type)
+                break
+            case "warnings":
+                this.main.warnings = event.warnings || []
+                return false
+            default:
+                return false
+            }
+            return true
         },
 
 
@@ -145,34 +239,62 @@ var app = new Vue({
             // empty current Graph contents
             this.main.g.html('')
 
+            this.main.linkTypes = Array.from(new Set(this.main.graph.links.map(l => l.type))).sort()
+            let hidden = this.main.hiddenLinkTypes
+
             this.main.linkElements = this.main.g.append("g")
                 .attr("class", "links")
                 .selectAll("line")
-                .data(this.main.graph.links)
+                .data(this.main.graph.links.filter(l => !hidden.includes(l.type)))
                 .enter().append("line")
                 .style("stroke-width", 3)
-                .style("stroke", "grey")
-        
-        
+                .style("stroke", function (d) {
+                    // services selecting pods that are not ready endpoints
+                    if (d.type === "selects" && d.detail === "not ready") return "orange"
+                    return (d.type === "owns") ? "grey" : linkColor(d.type)
+                })
+                .style("stroke-dasharray", function (d) {
+                    return (d.type === "selects") ? "6,3" : null
+                })
+
+            this.main.linkElements.append("title")
+                .text(function (d) {
+                    return d.detail ? d.type + " (" + d.detail + ")" : d.type
+                })
+
             this.main.nodeElements = this.main.g.append("g")
                 .attr("class", "nodes")
                 .selectAll("circle")
                 .data(this.main.graph.nodes)
                 .enter().append("circle")
                 .attr("r", radius)  // adjust this value to set radius of node
-                .attr("stroke", "#fff")
-                .attr('stroke-width', 21)
+                .attr("stroke", function (d) {
+                    return (d.status === "missing") ? color(d.kind) : "#fff"
+                })
+                .attr('stroke-width', function (d) {
+                    return (d.status === "missing") ? 6 : 21
+                })
+                .attr("stroke-dasharray", function (d) {
+                    // placeholders for referenced resources that do not exist
+                    return (d.status === "missing") ? "8,4" : null
+                })
                 .attr("id", function (d) {
                     return d.id
                 })
                 .attr("fill", function (d) {
-                    return color(d.kind)
+                    return (d.status === "missing") ? "#fff" : color(d.kind)
                 })
                 .on("click", this.selectNode)
                 .call(d3.drag()
                     .on("start", this.dragStarted)
                     .on("drag", this.dragged)
                     .on("end", this.dragEnded))
+
+            this.main.nodeElements.append("title")
+                .text(function (d) {
+                    let title = d.kind + "/" + d.name
+                    return d.summary ? title + "\n" + d.summary : title
+                })
         
             this.main.textElements = this.main.g.append("g")
                 .attr("class", "texts")
@@ -182,6 +304,7 @@ var app = new Vue({
                 .attr("text-anchor", "end")
                 .text(function (node) {
                     let label = node.kind + "/" + node.name
+                    if (node.status === "missing") label = "missing " + label
                     if (label.length > maxLabelLength) label = label.substring(0, maxLabelLength - 3) + "..."
                     return label
                 })
@@ -193,6 +316,17 @@ var app = new Vue({
                 .attr("text-anchor", "middle")
         },
 
+        toggleLinkType: function(type) {
+            let i = this.main.hiddenLinkTypes.indexOf(type)
+            if (i === -1) {
+                this.main.hiddenLinkTypes.push(type)
+            } else {
+                this.main.hiddenLinkTypes.splice(i, 1)
+            }
+            this.drawGraph()
+            this.ticked()
+        },
+
         ticked: function() {
             let that = this
 
@@ -246,11 +380,17 @@ var app = new Vue({
         },
 
         selectNode: function(d) {
+            if (d.status === "missing") {
+                this.overlay.text = d.kind + "/" + d.name + " is referenced but does not exist"
+                this.overlay.show = true
+                return
+            }
             if (!d.object) {
                 return
             }
 
             this.overlay.text = JSON.stringify(d.object, null, 2)
+            if (d.summary) this.overlay.text = d.summary + "\n\n" + this.overlay.text
             this.overlay.show = true
             this.$nextTick(() => this.$refs["nodedetails"].scrollTop = 0 )
         },
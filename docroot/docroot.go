@@ -0,0 +1,7 @@
+// Package docroot embeds the web application served by the server.
+package docroot
+
+import "embed"
+
+//go:embed *.html *.js *.css *.gif *.png *.svg
+var Content embed.FS
@@ -0,0 +1,63 @@
+// Package extractor lets programs outside this module graph additional
+// resource kinds. Extractors are registered from an init function and are
+// used by every graph the server builds:
+//
+//	func init() {
+//		extractor.Register(extractor.ResourceExtractor{
+//			Resource: schema.GroupVersionResource{Group: "example.com", Version: "v1", Resource: "widgets"},
+//			Label:    "widget",
+//		})
+//	}
+package extractor
+
+import "github.com/kwkoo/k8s-graph/internal"
+
+type (
+	// Extractor turns the items of a single resource type into graph nodes
+	// and links.
+	Extractor = internal.Extractor
+
+	// ResourceExtractor is an Extractor that adds a single node per item,
+	// links the item to its owners and optionally resolves further links.
+	ResourceExtractor = internal.ResourceExtractor
+
+	// Graph is the graph of a namespace that extractors add links and
+	// references to.
+	Graph = internal.Graph
+)
+
+// link types
+const (
+	LinkOwns       = internal.LinkOwns
+	LinkMounts     = internal.LinkMounts
+	LinkEnvFrom    = internal.LinkEnvFrom
+	LinkEnvKeyRef  = internal.LinkEnvKeyRef
+	LinkSelects    = internal.LinkSelects
+	LinkRoutesTo   = internal.LinkRoutesTo
+	LinkEndpoint   = internal.LinkEndpoint
+	LinkPullsImage = internal.LinkPullsImage
+	LinkPullSecret = internal.LinkPullSecret
+	LinkResolvesTo = internal.LinkResolvesTo
+	LinkProduces   = internal.LinkProduces
+	LinkTrigger    = internal.LinkTrigger
+	LinkBuildInput = internal.LinkBuildInput
+	LinkTLS        = internal.LinkTLS
+	LinkClass      = internal.LinkClass
+	LinkParent     = internal.LinkParent
+	LinkScales     = internal.LinkScales
+	LinkTraffic    = internal.LinkTraffic
+	LinkRunsAs     = internal.LinkRunsAs
+	LinkToken      = internal.LinkToken
+	LinkSubject    = internal.LinkSubject
+	LinkRoleRef    = internal.LinkRoleRef
+	LinkVolume     = internal.LinkVolume
+	LinkSource     = internal.LinkSource
+	LinkReferences = internal.LinkReferences
+)
+
+// Register adds an extractor to the set of extractors used to build every
+// graph. Registering an extractor for a kind that already has one replaces
+// the existing extractor.
+func Register(e Extractor) {
+	internal.RegisterExtractor(e)
+}
@@ -0,0 +1,180 @@
+package internal
+
+import (
+	"strings"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+// strategies of BuildConfigs and Builds
+var buildStrategies = []string{"sourceStrategy", "dockerStrategy", "customStrategy"}
+
+// buildStrategy returns the strategy of a BuildConfig or Build spec.
+func buildStrategy(spec map[string]interface{}) map[string]interface{} {
+	for _, strategy := range buildStrategies {
+		if s := unstructGetMap(spec, "strategy", strategy); s != nil {
+			return s
+		}
+	}
+	return nil
+}
+
+// the from reference of a BuildConfig or Build strategy
+func buildStrategyFrom(spec map[string]interface{}) map[string]interface{} {
+	return unstructGetMap(buildStrategy(spec), "from")
+}
+
+// buildImageRefs returns the from references of the builder image and the
+// source images of a BuildConfig.
+func buildImageRefs(spec map[string]interface{}) []map[string]interface{} {
+	refs := []map[string]interface{}{}
+	if from := buildStrategyFrom(spec); from != nil {
+		refs = append(refs, from)
+	}
+	for _, i := range unstructGetList(spec, "source", "images") {
+		if image, ok := i.(map[string]interface{}); ok {
+			if from := unstructGetMap(image, "from"); from != nil {
+				refs = append(refs, from)
+			}
+		}
+	}
+	return refs
+}
+
+// buildConfigImageNodes adds the nodes of the builder and source images that
+// a BuildConfig pulls by reference rather than from an image stream.
+func buildConfigImageNodes(graph *Graph, item unstructured.Unstructured) {
+	for _, from := range buildImageRefs(unstructGetMap(item.Object, "spec")) {
+		if unstructGetString(from, "kind") != "DockerImage" {
+			continue
+		}
+		if image := unstructGetString(from, "name"); image != "" {
+			addImageRefNode(graph, image)
+		}
+	}
+}
+
+// buildImageLinks links a BuildConfig to a builder or source image, either an
+// ImageStreamTag or an image pulled by reference.
+func buildImageLinks(graph *Graph, uid string, from map[string]interface{}, namespace, detail string) {
+	if target := imageStreamTagRef(graph, from, namespace); target != "" {
+		graph.AddReference(uid, "istag", target, LinkBuildInput, detail)
+		return
+	}
+	if unstructGetString(from, "kind") != "DockerImage" {
+		return
+	}
+	if image := unstructGetString(from, "name"); image != "" {
+		graph.AddLink(uid, imageRefNodeUid(image), LinkBuildInput, detail)
+	}
+}
+
+// webhook trigger types whose secret may be kept in a Secret
+var webhookTriggers = []string{"github", "gitlab", "bitbucket", "generic"}
+
+// BuildConfigs are linked to the tag they push to, to the tags whose changes
+// trigger a build, and to the builder images, secrets and config maps that
+// their builds use.
+func buildConfigLinks(graph *Graph, item unstructured.Unstructured) {
+	uid := string(item.GetUID())
+	spec := unstructGetMap(item.Object, "spec")
+	namespace := item.GetNamespace()
+
+	if target := imageStreamTagRef(graph, unstructGetMap(spec, "output", "to"), namespace); target != "" {
+		graph.AddReference(uid, "istag", target, LinkProduces, "")
+	}
+	if name := unstructGetString(spec, "output", "pushSecret", "name"); name != "" {
+		graph.AddReference(uid, "secret", name, LinkBuildInput, "push secret")
+	}
+	if name := unstructGetString(spec, "source", "sourceSecret", "name"); name != "" {
+		graph.AddReference(uid, "secret", name, LinkBuildInput, "source secret")
+	}
+
+	strategy := buildStrategy(spec)
+	buildImageLinks(graph, uid, unstructGetMap(strategy, "from"), namespace, "builder image")
+	if name := unstructGetString(strategy, "pullSecret", "name"); name != "" {
+		graph.AddReference(uid, "secret", name, LinkPullSecret, "")
+	}
+	envLinks(graph, uid, "build", unstructGetList(strategy, "env"))
+	for _, s := range unstructGetList(strategy, "secrets") {
+		// custom strategy secrets
+		secret, ok := s.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		if name := unstructGetString(secret, "secretSource", "name"); name != "" {
+			graph.AddReference(uid, "secret", name, LinkBuildInput, envKeyDetail("build secret", unstructGetString(secret, "mountPath")))
+		}
+	}
+
+	for _, i := range unstructGetList(spec, "source", "images") {
+		image, ok := i.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		buildImageLinks(graph, uid, unstructGetMap(image, "from"), namespace, "source image")
+		if name := unstructGetString(image, "pullSecret", "name"); name != "" {
+			graph.AddReference(uid, "secret", name, LinkPullSecret, "")
+		}
+	}
+	for _, s := range unstructGetList(spec, "source", "secrets") {
+		secret, ok := s.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		if name := unstructGetString(secret, "secret", "name"); name != "" {
+			graph.AddReference(uid, "secret", name, LinkBuildInput, envKeyDetail("build secret", unstructGetString(secret, "destinationDir")))
+		}
+	}
+	for _, c := range unstructGetList(spec, "source", "configMaps") {
+		configMap, ok := c.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		if name := unstructGetString(configMap, "configMap", "name"); name != "" {
+			graph.AddReference(uid, "cm", name, LinkBuildInput, envKeyDetail("build config map", unstructGetString(configMap, "destinationDir")))
+		}
+	}
+
+	for _, t := range unstructGetList(spec, "triggers") {
+		trigger, ok := t.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		for _, webhook := range webhookTriggers {
+			if name := unstructGetString(trigger, webhook, "secretReference", "name"); name != "" {
+				graph.AddReference(uid, "secret", name, LinkBuildInput, webhook+" webhook secret")
+			}
+		}
+		if unstructGetString(trigger, "type") != "ImageChange" {
+			continue
+		}
+		// image change triggers without a from follow the strategy's image
+		from := unstructGetMap(trigger, "imageChange", "from")
+		if from == nil {
+			from = buildStrategyFrom(spec)
+		}
+		if target := imageStreamTagRef(graph, from, item.GetNamespace()); target != "" {
+			graph.AddReference(uid, "istag", target, LinkTrigger, "")
+		}
+	}
+}
+
+// DeploymentConfigs are linked to the resources used by their pod template
+// and to the tags whose changes trigger a rollout.
+func deploymentConfigLinks(graph *Graph, item unstructured.Unstructured) {
+	uid := string(item.GetUID())
+	podSpecLinks(graph, uid, "", unstructGetMap(item.Object, "spec", "template", "spec"), nil)
+
+	for _, t := range unstructGetList(item.Object, "spec", "triggers") {
+		trigger, ok := t.(map[string]interface{})
+		if !ok || unstructGetString(trigger, "type") != "ImageChange" {
+			continue
+		}
+		params := unstructGetMap(trigger, "imageChangeParams")
+		if target := imageStreamTagRef(graph, unstructGetMap(params, "from"), item.GetNamespace()); target != "" {
+			containers := strings.Join(unstructGetStrings(params, "containerNames"), ",")
+			graph.AddReference(uid, "istag", target, LinkTrigger, containers)
+		}
+	}
+}
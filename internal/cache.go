@@ -0,0 +1,468 @@
+package internal
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sort"
+	"sync"
+	"time"
+
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/dynamic/dynamiclister"
+	"k8s.io/client-go/tools/cache"
+)
+
+// how often we check if a resource informer has synced
+const cacheSyncPollInterval = 100 * time.Millisecond
+
+// namespace key used when the cache watches all namespaces
+const clusterWideKey = ""
+
+// how long we wait before starting another informer for a resource that
+// could not be listed - doubled after every failure
+const (
+	cacheRetryMin = 5 * time.Second
+	cacheRetryMax = 5 * time.Minute
+)
+
+type CacheOptions struct {
+	Resync      time.Duration // informer resync period
+	IdleTimeout time.Duration // evict namespaces not accessed for this long - 0 disables eviction
+	ClusterWide bool          // use a single set of cluster-wide informers instead of per-namespace informers
+}
+
+type CacheStatus struct {
+	Namespace  string                `json:"namespace"`
+	LastAccess time.Time             `json:"lastaccess"`
+	Resources  []CacheResourceStatus `json:"resources"`
+}
+
+type CacheResourceStatus struct {
+	Resource string `json:"resource"`
+	Synced   bool   `json:"synced"`
+	Count    int    `json:"count"`
+	Err      string `json:"error,omitempty"`
+}
+
+// resourceCache holds dynamic informers for every resource that has been
+// requested. Informers are started lazily the first time a resource in a
+// namespace is listed.
+type resourceCache struct {
+	client      dynamic.Interface
+	opts        CacheOptions
+	mutex       sync.Mutex
+	namespaces  map[string]*namespaceCache
+	stop        chan struct{}
+	subMutex    sync.Mutex
+	subscribers map[string]map[chan struct{}]struct{} // namespace to notification channels
+}
+
+type namespaceCache struct {
+	namespace  string
+	lastAccess time.Time
+	resources  map[schema.GroupVersionResource]*cachedResource
+	failures   map[schema.GroupVersionResource]*cacheFailure
+}
+
+// cacheFailure records a resource whose informer could not be populated, so
+// that the same error is returned until retryAt instead of starting a new
+// informer for every request.
+type cacheFailure struct {
+	err     error
+	retryAt time.Time
+	backoff time.Duration
+}
+
+type cachedResource struct {
+	gvr      schema.GroupVersionResource
+	informer cache.SharedIndexInformer
+	lister   dynamiclister.Lister
+	stop     chan struct{}
+	mutex    sync.Mutex
+	err      error // last list / watch error
+}
+
+func newResourceCache(client dynamic.Interface, opts CacheOptions) *resourceCache {
+	rc := resourceCache{
+		client:      client,
+		opts:        opts,
+		namespaces:  make(map[string]*namespaceCache),
+		stop:        make(chan struct{}),
+		subscribers: make(map[string]map[chan struct{}]struct{}),
+	}
+
+	if opts.IdleTimeout > 0 && !opts.ClusterWide {
+		go rc.evictLoop()
+	}
+
+	return &rc
+}
+
+// list returns the cached items of the given resource in a namespace (or in
+// all namespaces if namespace is empty), starting an informer and waiting for
+// it to sync if necessary. The returned objects are shared with the cache and
+// must not be modified. If the latest list or watch of an informer that has
+// synced before failed, the cached items are returned together with the
+// error, so that the items are still graphed with a warning that they may be
+// stale.
+func (rc *resourceCache) list(ctx context.Context, gvr schema.GroupVersionResource, namespace string) ([]*unstructured.Unstructured, error) {
+	cr, err := rc.resource(gvr, namespace)
+	if err != nil {
+		return nil, err
+	}
+
+	for !cr.informer.HasSynced() {
+		if err := cr.lastError(); err != nil {
+			// the informer could not be populated - stop it so that a later
+			// request starts from scratch
+			rc.fail(gvr, namespace, cr, err)
+			return nil, err
+		}
+		select {
+		case <-ctx.Done():
+			return nil, fmt.Errorf("timed out waiting for %s cache to sync: %w", gvrString(gvr), ctx.Err())
+		case <-time.After(cacheSyncPollInterval):
+		}
+	}
+
+	var items []*unstructured.Unstructured
+	if namespace == "" {
+		items, err = cr.lister.List(labels.Everything())
+	} else {
+		items, err = cr.lister.Namespace(namespace).List(labels.Everything())
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := cr.lastError(); err != nil {
+		return items, err
+	}
+	rc.synced(gvr, namespace)
+	return items, nil
+}
+
+func (rc *resourceCache) cacheKey(namespace string) string {
+	if rc.opts.ClusterWide {
+		return clusterWideKey
+	}
+	return namespace
+}
+
+// resource returns the informer for the given resource, creating and starting
+// it if it does not exist yet. If the last informer for the resource failed
+// recently, its error is returned instead.
+func (rc *resourceCache) resource(gvr schema.GroupVersionResource, namespace string) (*cachedResource, error) {
+	key := rc.cacheKey(namespace)
+
+	rc.mutex.Lock()
+	defer rc.mutex.Unlock()
+
+	nc, ok := rc.namespaces[key]
+	if !ok {
+		nc = &namespaceCache{
+			namespace: key,
+			resources: make(map[schema.GroupVersionResource]*cachedResource),
+			failures:  make(map[schema.GroupVersionResource]*cacheFailure),
+		}
+		rc.namespaces[key] = nc
+	}
+	nc.lastAccess = time.Now()
+
+	if cr, ok := nc.resources[gvr]; ok {
+		return cr, nil
+	}
+	if f, ok := nc.failures[gvr]; ok && time.Now().Before(f.retryAt) {
+		return nil, f.err
+	}
+
+	cr := rc.startInformer(gvr, key)
+	nc.resources[gvr] = cr
+	return cr, nil
+}
+
+func (rc *resourceCache) startInformer(gvr schema.GroupVersionResource, namespace string) *cachedResource {
+	cr := cachedResource{
+		gvr:  gvr,
+		stop: make(chan struct{}),
+	}
+
+	// the reflector wraps list errors in plain strings, so errors are
+	// recorded here to keep their API status
+	cr.informer = cache.NewSharedIndexInformer(
+		&cache.ListWatch{
+			ListFunc: func(options v1.ListOptions) (runtime.Object, error) {
+				list, err := rc.client.Resource(gvr).Namespace(namespace).List(context.Background(), options)
+				cr.setError(err)
+				return list, err
+			},
+			WatchFunc: func(options v1.ListOptions) (watch.Interface, error) {
+				w, err := rc.client.Resource(gvr).Namespace(namespace).Watch(context.Background(), options)
+				cr.setError(err)
+				return w, err
+			},
+		},
+		&unstructured.Unstructured{},
+		rc.opts.Resync,
+		cache.Indexers{cache.NamespaceIndex: cache.MetaNamespaceIndexFunc},
+	)
+	cr.lister = dynamiclister.New(cr.informer.GetIndexer(), gvr)
+	cr.informer.AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc: rc.notifyObject,
+		UpdateFunc: func(oldObj, newObj interface{}) {
+			// resyncs deliver updates with unchanged objects
+			if objectResourceVersion(oldObj) == objectResourceVersion(newObj) {
+				return
+			}
+			rc.notifyObject(newObj)
+		},
+		DeleteFunc: rc.notifyObject,
+	})
+
+	if namespace == clusterWideKey {
+		log.Printf("starting cluster-wide informer for %s", gvrString(gvr))
+	} else {
+		log.Printf("starting informer for %s in namespace %s", gvrString(gvr), namespace)
+	}
+	go cr.informer.Run(cr.stop)
+
+	return &cr
+}
+
+// fail stops the informer for a resource if it is still the one registered
+// in the cache, and backs off before another informer is started for it.
+func (rc *resourceCache) fail(gvr schema.GroupVersionResource, namespace string, cr *cachedResource, err error) {
+	rc.mutex.Lock()
+	defer rc.mutex.Unlock()
+
+	nc, ok := rc.namespaces[rc.cacheKey(namespace)]
+	if !ok {
+		return
+	}
+	if existing, ok := nc.resources[gvr]; !ok || existing != cr {
+		return
+	}
+	close(cr.stop)
+	delete(nc.resources, gvr)
+
+	f, ok := nc.failures[gvr]
+	if !ok {
+		f = &cacheFailure{backoff: cacheRetryMin}
+		nc.failures[gvr] = f
+	} else if f.backoff *= 2; f.backoff > cacheRetryMax {
+		f.backoff = cacheRetryMax
+	}
+	f.err = err
+	f.retryAt = time.Now().Add(f.backoff)
+	log.Printf("could not list %s, retrying in %s: %v", gvrString(gvr), f.backoff, err)
+}
+
+// synced forgets earlier failures of a resource once its informer has synced.
+func (rc *resourceCache) synced(gvr schema.GroupVersionResource, namespace string) {
+	rc.mutex.Lock()
+	defer rc.mutex.Unlock()
+
+	if nc, ok := rc.namespaces[rc.cacheKey(namespace)]; ok {
+		delete(nc.failures, gvr)
+	}
+}
+
+func (rc *resourceCache) evictLoop() {
+	interval := rc.opts.IdleTimeout / 2
+	if interval < time.Second {
+		interval = time.Second
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-rc.stop:
+			return
+		case <-ticker.C:
+			rc.evict(time.Now().Add(-rc.opts.IdleTimeout))
+		}
+	}
+}
+
+// evict stops the informers of all namespaces that have not been accessed
+// since the cutoff.
+func (rc *resourceCache) evict(cutoff time.Time) {
+	rc.mutex.Lock()
+	defer rc.mutex.Unlock()
+
+	for key, nc := range rc.namespaces {
+		if nc.lastAccess.After(cutoff) || rc.hasSubscribers(key) {
+			continue
+		}
+		log.Printf("evicting idle namespace %s from the cache", key)
+		nc.stopAll()
+		delete(rc.namespaces, key)
+	}
+}
+
+func (rc *resourceCache) close() {
+	rc.mutex.Lock()
+	defer rc.mutex.Unlock()
+
+	close(rc.stop)
+	for key, nc := range rc.namespaces {
+		nc.stopAll()
+		delete(rc.namespaces, key)
+	}
+}
+
+// subscribeChannel adds a notification channel to the subscribers of a
+// namespace. The channel receives a value whenever a cached resource in the
+// namespace is added, updated or deleted. Notifications are coalesced - a
+// receiver is only guaranteed to be notified at least once after a change.
+// A single channel may be subscribed to several namespaces and must be
+// buffered. The returned function cancels the subscription.
+func (rc *resourceCache) subscribeChannel(namespace string, ch chan struct{}) func() {
+	rc.subMutex.Lock()
+	defer rc.subMutex.Unlock()
+
+	subs, ok := rc.subscribers[namespace]
+	if !ok {
+		subs = make(map[chan struct{}]struct{})
+		rc.subscribers[namespace] = subs
+	}
+	subs[ch] = struct{}{}
+
+	return func() {
+		rc.subMutex.Lock()
+		defer rc.subMutex.Unlock()
+
+		subs := rc.subscribers[namespace]
+		delete(subs, ch)
+		if len(subs) == 0 {
+			delete(rc.subscribers, namespace)
+		}
+	}
+}
+
+func (rc *resourceCache) hasSubscribers(namespace string) bool {
+	rc.subMutex.Lock()
+	defer rc.subMutex.Unlock()
+
+	if namespace == clusterWideKey {
+		return len(rc.subscribers) > 0
+	}
+	return len(rc.subscribers[namespace]) > 0
+}
+
+func (rc *resourceCache) notifyObject(obj interface{}) {
+	key, err := cache.DeletionHandlingMetaNamespaceKeyFunc(obj)
+	if err != nil {
+		return
+	}
+	namespace, _, err := cache.SplitMetaNamespaceKey(key)
+	if err != nil {
+		return
+	}
+	rc.notify(namespace)
+}
+
+// notify wakes up the subscribers of a namespace. Changes to cluster-scoped
+// resources (with an empty namespace) may affect any namespace, so every
+// subscriber is notified.
+func (rc *resourceCache) notify(namespace string) {
+	rc.subMutex.Lock()
+	defer rc.subMutex.Unlock()
+
+	for ns, subs := range rc.subscribers {
+		if namespace != "" && ns != namespace {
+			continue
+		}
+		for ch := range subs {
+			select {
+			case ch <- struct{}{}:
+			default:
+				// a notification is already pending
+			}
+		}
+	}
+}
+
+func (rc *resourceCache) status() []CacheStatus {
+	rc.mutex.Lock()
+	defer rc.mutex.Unlock()
+
+	all := []CacheStatus{}
+	for _, nc := range rc.namespaces {
+		status := CacheStatus{
+			Namespace:  nc.namespace,
+			LastAccess: nc.lastAccess,
+			Resources:  []CacheResourceStatus{},
+		}
+		for _, cr := range nc.resources {
+			rs := CacheResourceStatus{
+				Resource: gvrString(cr.gvr),
+				Synced:   cr.informer.HasSynced(),
+				Count:    len(cr.informer.GetStore().ListKeys()),
+			}
+			if err := cr.lastError(); err != nil {
+				rs.Err = err.Error()
+			}
+			status.Resources = append(status.Resources, rs)
+		}
+		for gvr, f := range nc.failures {
+			if _, ok := nc.resources[gvr]; ok {
+				continue
+			}
+			status.Resources = append(status.Resources, CacheResourceStatus{
+				Resource: gvrString(gvr),
+				Err:      f.err.Error(),
+			})
+		}
+		sort.Slice(status.Resources, func(i, j int) bool {
+			return status.Resources[i].Resource < status.Resources[j].Resource
+		})
+		all = append(all, status)
+	}
+	sort.Slice(all, func(i, j int) bool {
+		return all[i].Namespace < all[j].Namespace
+	})
+
+	return all
+}
+
+func (nc *namespaceCache) stopAll() {
+	for gvr, cr := range nc.resources {
+		close(cr.stop)
+		delete(nc.resources, gvr)
+	}
+}
+
+func (cr *cachedResource) setError(err error) {
+	cr.mutex.Lock()
+	defer cr.mutex.Unlock()
+	cr.err = err
+}
+
+func (cr *cachedResource) lastError() error {
+	cr.mutex.Lock()
+	defer cr.mutex.Unlock()
+	return cr.err
+}
+
+func objectResourceVersion(obj interface{}) string {
+	u, ok := obj.(*unstructured.Unstructured)
+	if !ok {
+		return ""
+	}
+	return u.GetResourceVersion()
+}
+
+func gvrString(gvr schema.GroupVersionResource) string {
+	if gvr.Group == "" {
+		return fmt.Sprintf("%s/%s", gvr.Version, gvr.Resource)
+	}
+	return fmt.Sprintf("%s/%s/%s", gvr.Group, gvr.Version, gvr.Resource)
+}
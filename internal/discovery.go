@@ -0,0 +1,189 @@
+package internal
+
+import (
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/discovery"
+)
+
+type DiscoveryOptions struct {
+	Enabled bool          // graph every listable namespaced resource
+	Include []string      // only graph these resources - all resources if empty
+	Exclude []string      // never graph these resources
+	Refresh time.Duration // how often the server's resources are rediscovered - 0 disables refreshes
+}
+
+// refreshDiscovery looks up the preferred version of every resource served
+// by the API server and, if enabled, the generic extractors for discovered
+// resources.
+func (kc *KubeClient) refreshDiscovery() error {
+	failedGroups := make(map[string]struct{})
+	lists, err := discovery.ServerPreferredResources(kc.discClient)
+	if err != nil {
+		failed, ok := err.(*discovery.ErrGroupDiscoveryFailed)
+		if !ok {
+			return fmt.Errorf("error discovering resources: %v", err)
+		}
+		// some aggregated API servers may be down - carry on with the groups
+		// that could be discovered
+		log.Printf("partial resource discovery: %v", err)
+		for gv := range failed.Groups {
+			failedGroups[gv.Group] = struct{}{}
+		}
+	}
+
+	versions := make(map[schema.GroupResource]string)
+	for _, list := range lists {
+		gv, err := schema.ParseGroupVersion(list.GroupVersion)
+		if err != nil {
+			continue
+		}
+		for _, resource := range list.APIResources {
+			if strings.Contains(resource.Name, "/") {
+				continue
+			}
+			versions[schema.GroupResource{Group: gv.Group, Resource: resource.Name}] = gv.Version
+		}
+	}
+
+	var discovered []Extractor
+	if kc.discoveryOpts.Enabled {
+		if discovered, err = discoverExtractors(lists, kc.discoveryOpts); err != nil {
+			return err
+		}
+	}
+
+	kc.discoveryMutex.Lock()
+	defer kc.discoveryMutex.Unlock()
+	if kc.discoveryOpts.Enabled && len(discovered) != len(kc.discovered) {
+		log.Printf("discovered %d additional resources to graph", len(discovered))
+	}
+	kc.versions = versions
+	kc.failedGroups = failedGroups
+	kc.discovered = discovered
+
+	return nil
+}
+
+func (kc *KubeClient) refreshDiscoveryLoop() {
+	ticker := time.NewTicker(kc.discoveryOpts.Refresh)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-kc.stop:
+			return
+		case <-ticker.C:
+			if err := kc.refreshDiscovery(); err != nil {
+				log.Print(err)
+			}
+		}
+	}
+}
+
+// resolveGVR replaces the version of a resource with the version preferred
+// by the API server. The resource is returned unchanged if discovery has not
+// succeeded yet or failed for the resource's group.
+func (kc *KubeClient) resolveGVR(gvr schema.GroupVersionResource) (schema.GroupVersionResource, error) {
+	kc.discoveryMutex.RLock()
+	defer kc.discoveryMutex.RUnlock()
+
+	if len(kc.versions) == 0 {
+		return gvr, nil
+	}
+	if _, ok := kc.failedGroups[gvr.Group]; ok {
+		return gvr, nil
+	}
+	version, ok := kc.versions[gvr.GroupResource()]
+	if !ok {
+		return gvr, notServedError{gr: gvr.GroupResource()}
+	}
+	return gvr.GroupResource().WithVersion(version), nil
+}
+
+// discoveredExtractors returns the generic extractors for discovered
+// resources.
+func (kc *KubeClient) discoveredExtractors() []Extractor {
+	kc.discoveryMutex.RLock()
+	defer kc.discoveryMutex.RUnlock()
+
+	return kc.discovered
+}
+
+// discoverExtractors returns generic extractors for every namespaced
+// resource that the server can list and watch, and that is not already
+// handled by a registered extractor. Resources in the include and exclude
+// lists are specified either as resource.group (resource for the core group)
+// or as a group.
+func discoverExtractors(lists []*v1.APIResourceList, opts DiscoveryOptions) ([]Extractor, error) {
+	lists = discovery.FilteredBy(discovery.ResourcePredicateFunc(func(groupVersion string, r *v1.APIResource) bool {
+		return r.Namespaced
+	}), lists)
+	lists = discovery.FilteredBy(discovery.SupportsAllVerbs{Verbs: []string{"list", "watch"}}, lists)
+
+	handled := make(map[schema.GroupResource]struct{})
+	extractors, err := registry.ordered()
+	if err != nil {
+		return nil, err
+	}
+	for _, e := range extractors {
+		handled[e.GVR().GroupResource()] = struct{}{}
+	}
+
+	discovered := []Extractor{}
+	for _, list := range lists {
+		gv, err := schema.ParseGroupVersion(list.GroupVersion)
+		if err != nil {
+			continue
+		}
+		for _, resource := range list.APIResources {
+			// skip subresources
+			if strings.Contains(resource.Name, "/") {
+				continue
+			}
+			gr := schema.GroupResource{Group: gv.Group, Resource: resource.Name}
+			if _, ok := handled[gr]; ok {
+				continue
+			}
+			if !resourceSelected(gr, opts) {
+				continue
+			}
+			discovered = append(discovered, ResourceExtractor{
+				Resource: gv.WithResource(resource.Name),
+				Label:    genericKind(gv.Group, resource),
+			})
+		}
+	}
+
+	return discovered, nil
+}
+
+func resourceSelected(gr schema.GroupResource, opts DiscoveryOptions) bool {
+	if len(opts.Include) > 0 && !resourceListed(gr, opts.Include) {
+		return false
+	}
+	return !resourceListed(gr, opts.Exclude)
+}
+
+func resourceListed(gr schema.GroupResource, list []string) bool {
+	for _, entry := range list {
+		if entry == gr.String() || (gr.Group != "" && entry == gr.Group) {
+			return true
+		}
+	}
+	return false
+}
+
+// nodes of discovered resources are labelled group/Kind so that kinds with
+// the same name in different groups can be told apart
+func genericKind(group string, resource v1.APIResource) string {
+	if group == "" {
+		return resource.Kind
+	}
+	return fmt.Sprintf("%s/%s", group, resource.Kind)
+}
@@ -0,0 +1,216 @@
+package internal
+
+import (
+	"fmt"
+	"sync"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// Extractor turns the items of a single resource type into graph nodes and
+// links.
+type Extractor interface {
+	// GVR is the resource that is listed.
+	GVR() schema.GroupVersionResource
+
+	// Kind is the short label given to the nodes of this resource, e.g.
+	// "pod". Other extractors refer to these nodes by kind and name.
+	Kind() string
+
+	// OpenShiftOnly returns true if the resource only exists on OpenShift.
+	OpenShiftOnly() bool
+
+	// ClusterScoped returns true if the resource is not namespaced. Nodes of
+	// cluster-scoped resources are only kept if they can be reached from the
+	// namespace's nodes.
+	ClusterScoped() bool
+
+	// AllNamespaces returns true if resources in other namespaces can be
+	// referenced, e.g. gateways. Nodes in other namespaces are named
+	// namespace/name and are only kept if they can be reached from the
+	// namespace's nodes.
+	AllNamespaces() bool
+
+	// Optional returns true if the resource is provided by an add-on that may
+	// not be installed. Optional resources are skipped without a warning if
+	// the API server does not serve them.
+	Optional() bool
+
+	// Dependencies lists the kinds whose nodes this extractor links to.
+	// Extractors are always processed after the extractors of their
+	// dependencies.
+	Dependencies() []string
+
+	// AddNodes adds the nodes for a single item to the graph.
+	AddNodes(graph *Graph, item unstructured.Unstructured)
+
+	// AddLinks adds the links for a single item to the graph. It is only
+	// called once the nodes of every item of every kind have been added.
+	AddLinks(graph *Graph, item unstructured.Unstructured)
+}
+
+// ResourceExtractor is an Extractor that adds a single node per item, links
+// the item to its owners and optionally resolves further links.
+type ResourceExtractor struct {
+	Resource     schema.GroupVersionResource
+	Label        string
+	OpenShift    bool
+	ClusterScope bool
+	AllNs        bool
+	Addon        bool
+	DependsOn    []string
+
+	// ExtraNodes adds nodes other than the node of the item itself - may be
+	// nil.
+	ExtraNodes func(graph *Graph, item unstructured.Unstructured)
+
+	// Links adds links other than the owner links - may be nil.
+	Links func(graph *Graph, item unstructured.Unstructured)
+
+	// Summary describes the state of the item in the node's summary - may be
+	// nil.
+	Summary func(item unstructured.Unstructured) string
+
+	// NoOwnerLinks disables linking the item to its owners.
+	NoOwnerLinks bool
+}
+
+func (e ResourceExtractor) GVR() schema.GroupVersionResource { return e.Resource }
+
+func (e ResourceExtractor) Kind() string { return e.Label }
+
+func (e ResourceExtractor) OpenShiftOnly() bool { return e.OpenShift }
+
+func (e ResourceExtractor) ClusterScoped() bool { return e.ClusterScope }
+
+func (e ResourceExtractor) AllNamespaces() bool { return e.AllNs }
+
+func (e ResourceExtractor) Optional() bool { return e.Addon }
+
+func (e ResourceExtractor) Dependencies() []string { return e.DependsOn }
+
+func (e ResourceExtractor) AddNodes(graph *Graph, item unstructured.Unstructured) {
+	uid := string(item.GetUID())
+	if graph.nodeExists(uid) {
+		// the same object is served by more than one API group, e.g.
+		// ingresses in extensions and networking.k8s.io - registered
+		// extractors run before discovered ones, so their node is kept
+		return
+	}
+	name := item.GetName()
+	external := e.ClusterScope
+	if ns := item.GetNamespace(); ns != "" && ns != graph.namespace {
+		name = qualifiedName(ns, name)
+		external = true
+	}
+	graph.AddNode(uid, e.Label, name, item.Object)
+	if e.Summary != nil {
+		graph.nodeMap[uid].Summary = e.Summary(item)
+	}
+	if external {
+		graph.markExternal(uid, item.GetNamespace())
+	}
+	if e.ExtraNodes != nil {
+		e.ExtraNodes(graph, item)
+	}
+}
+
+func (e ResourceExtractor) AddLinks(graph *Graph, item unstructured.Unstructured) {
+	if !e.NoOwnerLinks {
+		addOwnerLinks(item, graph)
+	}
+	if e.Links != nil {
+		e.Links(graph, item)
+	}
+}
+
+type extractorRegistry struct {
+	mutex      sync.Mutex
+	extractors []Extractor
+	kinds      map[string]int // kind to index in extractors
+}
+
+var registry = extractorRegistry{kinds: make(map[string]int)}
+
+// RegisterExtractor adds an extractor to the set of extractors used to build
+// every graph. Registering an extractor for a kind that already has one
+// replaces the existing extractor.
+func RegisterExtractor(e Extractor) {
+	registry.register(e)
+}
+
+func (r *extractorRegistry) register(e Extractor) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if i, ok := r.kinds[e.Kind()]; ok {
+		r.extractors[i] = e
+		return
+	}
+	r.kinds[e.Kind()] = len(r.extractors)
+	r.extractors = append(r.extractors, e)
+}
+
+// byGVR returns the extractor for a resource or nil if there is none. The
+// version is ignored as it is negotiated with the API server.
+func (r *extractorRegistry) byGVR(gvr schema.GroupVersionResource) Extractor {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	for _, e := range r.extractors {
+		if e.GVR().GroupResource() == gvr.GroupResource() {
+			return e
+		}
+	}
+	return nil
+}
+
+// ordered returns the registered extractors sorted so that every extractor
+// comes after the extractors of the kinds it depends on. Extractors without
+// dependencies between them keep their registration order.
+func (r *extractorRegistry) ordered() ([]Extractor, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	remaining := make([]Extractor, len(r.extractors))
+	copy(remaining, r.extractors)
+	done := make(map[string]struct{})
+	ordered := make([]Extractor, 0, len(remaining))
+
+	for len(remaining) > 0 {
+		progress := false
+		for i, e := range remaining {
+			if !r.dependenciesDone(e, done) {
+				continue
+			}
+			ordered = append(ordered, e)
+			done[e.Kind()] = struct{}{}
+			remaining = append(remaining[:i], remaining[i+1:]...)
+			progress = true
+			break
+		}
+		if !progress {
+			kinds := []string{}
+			for _, e := range remaining {
+				kinds = append(kinds, e.Kind())
+			}
+			return nil, fmt.Errorf("circular extractor dependencies between %v", kinds)
+		}
+	}
+
+	return ordered, nil
+}
+
+// dependencies on kinds without a registered extractor are ignored
+func (r *extractorRegistry) dependenciesDone(e Extractor, done map[string]struct{}) bool {
+	for _, dep := range e.Dependencies() {
+		if _, registered := r.kinds[dep]; !registered {
+			continue
+		}
+		if _, ok := done[dep]; !ok {
+			return false
+		}
+	}
+	return true
+}
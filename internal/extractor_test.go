@@ -0,0 +1,43 @@
+package internal
+
+import (
+	"testing"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// Objects served by two API groups are only graphed once, by the extractor
+// that sees them first.
+func TestResourceExtractorDuplicateUid(t *testing.T) {
+	item := unstructured.Unstructured{Object: map[string]interface{}{
+		"metadata": map[string]interface{}{
+			"name":      "web",
+			"namespace": "test",
+			"uid":       "1234",
+		},
+	}}
+	registered := ResourceExtractor{
+		Resource: schema.GroupVersionResource{Group: "networking.k8s.io", Version: "v1", Resource: "ingresses"},
+		Label:    "ingress",
+	}
+	discovered := ResourceExtractor{
+		Resource: schema.GroupVersionResource{Group: "extensions", Version: "v1beta1", Resource: "ingresses"},
+		Label:    "extensions/Ingress",
+	}
+
+	graph := InitGraph()
+	graph.namespace = "test"
+	registered.AddNodes(graph, item)
+	discovered.AddNodes(graph, item)
+
+	if len(graph.Nodes) != 1 {
+		t.Fatalf("got %d nodes - expected 1", len(graph.Nodes))
+	}
+	if kind := graph.Nodes[0].Kind; kind != "ingress" {
+		t.Errorf("got node of kind %s - expected ingress", kind)
+	}
+	if uid := graph.FindResource("ingress", "web"); uid != "1234" {
+		t.Errorf("FindResource() = %q - expected 1234", uid)
+	}
+}
@@ -0,0 +1,1115 @@
+package internal
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// the built-in extractors
+func init() {
+	RegisterExtractor(ResourceExtractor{
+		Resource:     schema.GroupVersionResource{Group: "", Version: "v1", Resource: "configmaps"},
+		Label:        "cm",
+		NoOwnerLinks: true,
+	})
+	RegisterExtractor(ResourceExtractor{
+		Resource:     schema.GroupVersionResource{Group: "", Version: "v1", Resource: "secrets"},
+		Label:        "secret",
+		NoOwnerLinks: true,
+	})
+	RegisterExtractor(ResourceExtractor{
+		Resource:     schema.GroupVersionResource{Group: "storage.k8s.io", Version: "v1", Resource: "storageclasses"},
+		Label:        "storageclass",
+		ClusterScope: true,
+		Summary:      storageClassSummary,
+	})
+	RegisterExtractor(ResourceExtractor{
+		Resource:     schema.GroupVersionResource{Group: "", Version: "v1", Resource: "persistentvolumes"},
+		Label:        "pv",
+		ClusterScope: true,
+		DependsOn:    []string{"storageclass"},
+		Links:        pvLinks,
+		Summary:      volumeSummary,
+	})
+	// PVCs restored from a snapshot refer to VolumeSnapshots, which in turn
+	// refer to PVCs - the reference is resolved without a dependency
+	RegisterExtractor(ResourceExtractor{
+		Resource:     schema.GroupVersionResource{Group: "", Version: "v1", Resource: "persistentvolumeclaims"},
+		Label:        "pvc",
+		NoOwnerLinks: true,
+		DependsOn:    []string{"pv", "storageclass"},
+		Links:        pvcLinks,
+		Summary:      volumeSummary,
+	})
+	RegisterExtractor(ResourceExtractor{
+		Resource:  schema.GroupVersionResource{Group: "snapshot.storage.k8s.io", Version: "v1", Resource: "volumesnapshots"},
+		Label:     "volumesnapshot",
+		Addon:     true,
+		DependsOn: []string{"pvc"},
+		Links:     volumeSnapshotLinks,
+		Summary:   volumeSnapshotSummary,
+	})
+	RegisterExtractor(ResourceExtractor{
+		Resource:   schema.GroupVersionResource{Group: "batch", Version: "v1", Resource: "cronjobs"},
+		Label:      "cj",
+		DependsOn:  podSpecDependencies,
+		ExtraNodes: templateImageNodes("spec", "jobTemplate", "spec", "template", "spec"),
+		Links:      templateLinks("spec", "jobTemplate", "spec", "template", "spec"),
+	})
+	RegisterExtractor(ResourceExtractor{
+		Resource:   schema.GroupVersionResource{Group: "batch", Version: "v1", Resource: "jobs"},
+		Label:      "job",
+		DependsOn:  podSpecDependencies,
+		ExtraNodes: templateImageNodes("spec", "template", "spec"),
+		Links:      templateLinks("spec", "template", "spec"),
+	})
+	RegisterExtractor(ResourceExtractor{
+		Resource:   schema.GroupVersionResource{Group: "apps.openshift.io", Version: "v1", Resource: "deploymentconfigs"},
+		Label:      "dc",
+		OpenShift:  true,
+		DependsOn:  append([]string{"is"}, podSpecDependencies...),
+		ExtraNodes: templateImageNodes("spec", "template", "spec"),
+		Links:      deploymentConfigLinks,
+	})
+	RegisterExtractor(ResourceExtractor{
+		Resource:   schema.GroupVersionResource{Group: "image.openshift.io", Version: "v1", Resource: "imagestreams"},
+		Label:      "is",
+		OpenShift:  true,
+		AllNs:      true,
+		ExtraNodes: imageStreamNodes,
+		Links:      imageStreamLinks,
+	})
+	RegisterExtractor(ResourceExtractor{
+		Resource:   schema.GroupVersionResource{Group: "build.openshift.io", Version: "v1", Resource: "buildconfigs"},
+		Label:      "buildconfig",
+		OpenShift:  true,
+		DependsOn:  []string{"is", "secret", "cm"},
+		ExtraNodes: buildConfigImageNodes,
+		Links:      buildConfigLinks,
+	})
+	RegisterExtractor(ResourceExtractor{
+		Resource:   schema.GroupVersionResource{Group: "build.openshift.io", Version: "v1", Resource: "builds"},
+		Label:      "build",
+		OpenShift:  true,
+		DependsOn:  []string{"is"},
+		ExtraNodes: buildImageNodes,
+		Links:      buildLinks,
+	})
+	RegisterExtractor(ResourceExtractor{
+		Resource:   schema.GroupVersionResource{Group: "apps", Version: "v1", Resource: "deployments"},
+		Label:      "deployment",
+		DependsOn:  podSpecDependencies,
+		ExtraNodes: templateImageNodes("spec", "template", "spec"),
+		Links:      templateLinks("spec", "template", "spec"),
+	})
+	RegisterExtractor(ResourceExtractor{
+		Resource:   schema.GroupVersionResource{Group: "apps", Version: "v1", Resource: "statefulsets"},
+		Label:      "sts",
+		DependsOn:  podSpecDependencies,
+		ExtraNodes: templateImageNodes("spec", "template", "spec"),
+		Links:      statefulSetLinks,
+	})
+	RegisterExtractor(ResourceExtractor{
+		Resource:   schema.GroupVersionResource{Group: "apps", Version: "v1", Resource: "daemonsets"},
+		Label:      "ds",
+		DependsOn:  podSpecDependencies,
+		ExtraNodes: templateImageNodes("spec", "template", "spec"),
+		Links:      templateLinks("spec", "template", "spec"),
+	})
+	RegisterExtractor(ResourceExtractor{
+		Resource: schema.GroupVersionResource{Group: "apps", Version: "v1", Resource: "replicasets"},
+		Label:    "replicaset",
+	})
+	RegisterExtractor(ResourceExtractor{
+		Resource: schema.GroupVersionResource{Group: "", Version: "v1", Resource: "replicationcontrollers"},
+		Label:    "rc",
+	})
+	RegisterExtractor(ResourceExtractor{
+		Resource:  schema.GroupVersionResource{Group: "autoscaling", Version: "v2", Resource: "horizontalpodautoscalers"},
+		Label:     "hpa",
+		DependsOn: []string{"deployment", "sts", "dc", "replicaset", "rc"},
+		Links:     hpaLinks,
+		Summary:   hpaSummary,
+	})
+	RegisterExtractor(ResourceExtractor{
+		Resource:  schema.GroupVersionResource{Group: "", Version: "v1", Resource: "serviceaccounts"},
+		Label:     "sa",
+		DependsOn: []string{"secret"},
+		Links:     serviceAccountLinks,
+	})
+	RegisterExtractor(ResourceExtractor{
+		Resource: schema.GroupVersionResource{Group: "rbac.authorization.k8s.io", Version: "v1", Resource: "roles"},
+		Label:    "role",
+		Summary:  roleSummary,
+	})
+	RegisterExtractor(ResourceExtractor{
+		Resource:     schema.GroupVersionResource{Group: "rbac.authorization.k8s.io", Version: "v1", Resource: "clusterroles"},
+		Label:        "clusterrole",
+		ClusterScope: true,
+		Summary:      roleSummary,
+	})
+	RegisterExtractor(ResourceExtractor{
+		Resource:  schema.GroupVersionResource{Group: "rbac.authorization.k8s.io", Version: "v1", Resource: "rolebindings"},
+		Label:     "rolebinding",
+		DependsOn: []string{"sa", "role", "clusterrole"},
+		Links:     roleBindingLinks,
+	})
+	RegisterExtractor(ResourceExtractor{
+		Resource:     schema.GroupVersionResource{Group: "rbac.authorization.k8s.io", Version: "v1", Resource: "clusterrolebindings"},
+		Label:        "clusterrolebinding",
+		ClusterScope: true,
+		DependsOn:    []string{"sa", "clusterrole"},
+		Links:        roleBindingLinks,
+	})
+	RegisterExtractor(ResourceExtractor{
+		Resource:   schema.GroupVersionResource{Group: "", Version: "v1", Resource: "pods"},
+		Label:      "pod",
+		DependsOn:  podSpecDependencies,
+		ExtraNodes: podImageNodes,
+		Links:      podLinks,
+	})
+	RegisterExtractor(ResourceExtractor{
+		Resource:  schema.GroupVersionResource{Group: "", Version: "v1", Resource: "services"},
+		Label:     "svc",
+		DependsOn: []string{"pod", "endpointslice"},
+		Links:     serviceLinks,
+	})
+	RegisterExtractor(ResourceExtractor{
+		Resource:  schema.GroupVersionResource{Group: "policy", Version: "v1", Resource: "poddisruptionbudgets"},
+		Label:     "pdb",
+		DependsOn: []string{"pod"},
+		Links:     pdbLinks,
+		Summary:   pdbSummary,
+	})
+	RegisterExtractor(ResourceExtractor{
+		Resource:  schema.GroupVersionResource{Group: "networking.k8s.io", Version: "v1", Resource: "networkpolicies"},
+		Label:     "netpol",
+		DependsOn: []string{"pod"},
+		Links:     netpolLinks,
+		Summary:   netpolSummary,
+	})
+	RegisterExtractor(ResourceExtractor{
+		Resource:  schema.GroupVersionResource{Group: "route.openshift.io", Version: "v1", Resource: "routes"},
+		Label:     "route",
+		OpenShift: true,
+		DependsOn: []string{"svc"},
+		Links:     routeLinks,
+	})
+	RegisterExtractor(ResourceExtractor{
+		Resource:     schema.GroupVersionResource{Group: "networking.k8s.io", Version: "v1", Resource: "ingressclasses"},
+		Label:        "ingressclass",
+		ClusterScope: true,
+	})
+	RegisterExtractor(ResourceExtractor{
+		Resource:  schema.GroupVersionResource{Group: "networking.k8s.io", Version: "v1", Resource: "ingresses"},
+		Label:     "ingress",
+		DependsOn: []string{"svc", "secret", "ingressclass"},
+		Links:     ingressLinks,
+	})
+	RegisterExtractor(ResourceExtractor{
+		Resource:     schema.GroupVersionResource{Group: "gateway.networking.k8s.io", Version: "v1", Resource: "gatewayclasses"},
+		Label:        "gatewayclass",
+		ClusterScope: true,
+		Addon:        true,
+	})
+	RegisterExtractor(ResourceExtractor{
+		Resource:  schema.GroupVersionResource{Group: "gateway.networking.k8s.io", Version: "v1", Resource: "gateways"},
+		Label:     "gateway",
+		AllNs:     true,
+		Addon:     true,
+		DependsOn: []string{"secret", "gatewayclass"},
+		Links:     gatewayLinks,
+	})
+	RegisterExtractor(ResourceExtractor{
+		Resource:  schema.GroupVersionResource{Group: "gateway.networking.k8s.io", Version: "v1", Resource: "httproutes"},
+		Label:     "httproute",
+		Addon:     true,
+		DependsOn: []string{"gateway", "svc"},
+		Links:     gatewayRouteLinks,
+	})
+	RegisterExtractor(ResourceExtractor{
+		Resource:  schema.GroupVersionResource{Group: "gateway.networking.k8s.io", Version: "v1", Resource: "grpcroutes"},
+		Label:     "grpcroute",
+		Addon:     true,
+		DependsOn: []string{"gateway", "svc"},
+		Links:     gatewayRouteLinks,
+	})
+	RegisterExtractor(ResourceExtractor{
+		Resource:  schema.GroupVersionResource{Group: "gateway.networking.k8s.io", Version: "v1alpha2", Resource: "tlsroutes"},
+		Label:     "tlsroute",
+		Addon:     true,
+		DependsOn: []string{"gateway", "svc"},
+		Links:     gatewayRouteLinks,
+	})
+	RegisterExtractor(ResourceExtractor{
+		Resource:  schema.GroupVersionResource{Group: "discovery.k8s.io", Version: "v1", Resource: "endpointslices"},
+		Label:     "endpointslice",
+		DependsOn: []string{"pod"},
+		Links:     endpointSliceLinks,
+	})
+}
+
+// Builds are linked to the image they produce.
+func buildImageNodes(graph *Graph, item unstructured.Unstructured) {
+	imageDigest := unstructGetString(item.Object, "status", "output", "to", "imageDigest")
+	if imageDigest == "" {
+		return
+	}
+	name := imageDigest
+	if output := unstructGetString(item.Object, "status", "outputDockerImageReference"); output != "" {
+		ref, _ := normalizeImage(output)
+		name = imageDigestName(ref, imageDigest)
+	}
+	addImageNode(graph, imageDigestUid(imageDigest), name)
+}
+
+// Builds are linked to the image they produce and to the tag they push it to.
+func buildLinks(graph *Graph, item unstructured.Unstructured) {
+	uid := string(item.GetUID())
+	if target := imageStreamTagRef(graph, unstructGetMap(item.Object, "spec", "output", "to"), item.GetNamespace()); target != "" {
+		graph.AddReference(uid, "istag", target, LinkProduces, "")
+	}
+
+	imageDigest := unstructGetString(item.Object, "status", "output", "to", "imageDigest")
+	if imageDigest == "" {
+		return
+	}
+	graph.AddLink(uid, imageDigestUid(imageDigest), LinkProduces, "")
+}
+
+// the uid of an image node is its digest without the algorithm prefix
+func imageDigestUid(imageDigest string) string {
+	colon := strings.LastIndex(imageDigest, ":")
+	if colon == -1 {
+		return imageDigest
+	}
+	return imageDigest[colon+1:]
+}
+
+func podLinks(graph *Graph, item unstructured.Unstructured) {
+	podSpecLinks(graph, string(item.GetUID()), item.GetName(), unstructGetMap(item.Object, "spec"), containerImageIDs(item.Object))
+}
+
+// kinds that pod specs refer to
+var podSpecDependencies = []string{"cm", "secret", "pvc", "sa"}
+
+// templateLinks returns a Links function that links workloads to the
+// resources used by the pod template at the given path, so that workloads
+// that are scaled to zero or between runs still show what they use.
+func templateLinks(path ...string) func(graph *Graph, item unstructured.Unstructured) {
+	return func(graph *Graph, item unstructured.Unstructured) {
+		podSpecLinks(graph, string(item.GetUID()), "", unstructGetMap(item.Object, path...), nil)
+	}
+}
+
+// StatefulSets are linked to the resources used by their pod template and to
+// the claims created from their volumeClaimTemplates, which are named
+// <template>-<statefulset>-<ordinal>.
+func statefulSetLinks(graph *Graph, item unstructured.Unstructured) {
+	uid := string(item.GetUID())
+	spec := unstructGetMap(item.Object, "spec", "template", "spec")
+	podSpecLinks(graph, uid, "", spec, nil)
+
+	mounts := volumeMounts(spec)
+	for _, t := range unstructGetList(item.Object, "spec", "volumeClaimTemplates") {
+		template, ok := t.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		templateName := unstructGetString(template, "metadata", "name")
+		prefix := fmt.Sprintf("%s-%s-", templateName, item.GetName())
+		for _, node := range graph.Nodes {
+			if node.Kind != "pvc" || !strings.HasPrefix(node.Name, prefix) {
+				continue
+			}
+			if _, err := strconv.Atoi(strings.TrimPrefix(node.Name, prefix)); err != nil {
+				continue
+			}
+			graph.AddLink(uid, node.Uid, LinkMounts, mountDetail(mounts[templateName], templateName))
+		}
+	}
+}
+
+// container lists of a pod spec
+var containerFields = []string{"initContainers", "containers", "ephemeralContainers"}
+
+// podSpecLinks links the resource with the given uid to the service account,
+// secrets, config maps, claims and images used by a pod spec. podName is
+// used to find the claims of generic ephemeral volumes and imageIDs holds
+// the image digests of the pod's containers - both are empty for templates.
+func podSpecLinks(graph *Graph, uid, podName string, spec map[string]interface{}, imageIDs map[string]string) {
+	// .spec.serviceAccount is the deprecated name of .spec.serviceAccountName
+	serviceAccount := unstructGetString(spec, "serviceAccountName")
+	if serviceAccount == "" {
+		serviceAccount = valueOr(unstructGetString(spec, "serviceAccount"), "default")
+	}
+	graph.AddReference(uid, "sa", serviceAccount, LinkRunsAs, "")
+
+	// registry credentials, including the ones inherited from the service
+	// account if they have not been added to the pod when it was created
+	for _, name := range pullSecretNames(spec) {
+		graph.AddReference(uid, "secret", name, LinkPullSecret, "")
+	}
+	if sa := graph.FindResource("sa", serviceAccount); sa != "" {
+		for _, name := range pullSecretNames(graph.nodeMap[sa].Object) {
+			graph.AddReference(uid, "secret", name, LinkPullSecret, "serviceaccount "+serviceAccount)
+		}
+	}
+
+	for _, field := range containerFields {
+		for _, c := range unstructGetList(spec, field) {
+			if container, ok := c.(map[string]interface{}); ok {
+				containerLinks(graph, uid, container, imageIDs)
+			}
+		}
+	}
+
+	mounts := volumeMounts(spec)
+	for _, v := range unstructGetList(spec, "volumes") {
+		volume, ok := v.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		volumeName := unstructGetString(volume, "name")
+		volumeLinks(graph, uid, podName, volume, mountDetail(mounts[volumeName], volumeName))
+	}
+}
+
+// volumeMounts maps the volume names of a pod spec to the containers
+// mounting them.
+func volumeMounts(spec map[string]interface{}) map[string][]string {
+	mounts := make(map[string][]string)
+	for _, field := range containerFields {
+		for _, c := range unstructGetList(spec, field) {
+			container, ok := c.(map[string]interface{})
+			if !ok {
+				continue
+			}
+			for _, m := range unstructGetList(container, "volumeMounts") {
+				if mount, ok := m.(map[string]interface{}); ok {
+					volumeName := unstructGetString(mount, "name")
+					mounts[volumeName] = append(mounts[volumeName], unstructGetString(container, "name"))
+				}
+			}
+		}
+	}
+	return mounts
+}
+
+// containerLinks links a resource to the image, config maps and secrets used
+// by one of its containers.
+func containerLinks(graph *Graph, uid string, container map[string]interface{}, imageIDs map[string]string) {
+	containerName := unstructGetString(container, "name")
+
+	containerImageLinks(graph, uid, container, imageIDs)
+
+	// check for envFrom[*]
+	for _, efitem := range unstructGetList(container, "envFrom") {
+		efitemmap, ok := efitem.(map[string]interface{})
+		if !ok {
+			continue
+		}
+
+		// check for envFrom[*].configMapRef.name
+		cmName := unstructGetString(efitemmap, "configMapRef", "name")
+		if cmName != "" {
+			graph.AddReference(uid, "cm", cmName, LinkEnvFrom, containerName)
+		} else {
+			// check for envFrom[*].secretRef.name
+			secretName := unstructGetString(efitemmap, "secretRef", "name")
+			if secretName != "" {
+				graph.AddReference(uid, "secret", secretName, LinkEnvFrom, containerName)
+			}
+		}
+	}
+
+	envLinks(graph, uid, containerName, unstructGetList(container, "env"))
+}
+
+// envLinks links a resource to the config maps and secrets that the values
+// of environment variables are taken from.
+func envLinks(graph *Graph, uid, containerName string, env []interface{}) {
+	for _, envItem := range env {
+		envMap, ok := envItem.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		vf := unstructGetMap(envMap, "valueFrom")
+		if vf == nil {
+			continue
+		}
+
+		// check for env[*].valueFrom.configMapKeyRef.name
+		cmName := unstructGetString(vf, "configMapKeyRef", "name")
+		if cmName != "" {
+			graph.AddReference(uid, "cm", cmName, LinkEnvKeyRef, envKeyDetail(containerName, unstructGetString(vf, "configMapKeyRef", "key")))
+		} else {
+			// check for env[*].valueFrom.secretKeyRef.name
+			secretName := unstructGetString(vf, "secretKeyRef", "name")
+			if secretName != "" {
+				graph.AddReference(uid, "secret", secretName, LinkEnvKeyRef, envKeyDetail(containerName, unstructGetString(vf, "secretKeyRef", "key")))
+			}
+		}
+	}
+}
+
+// volume plugins whose secretRef holds the credentials used to mount the
+// volume
+var secretRefVolumes = []string{"cephfs", "rbd", "iscsi", "flexVolume", "scaleIO", "storageos"}
+
+// volumeLinks links a resource to the claim, config maps and secrets used by
+// a pod volume.
+func volumeLinks(graph *Graph, uid, podName string, volume map[string]interface{}, detail string) {
+	if claimName := unstructGetString(volume, "persistentVolumeClaim", "claimName"); claimName != "" {
+		graph.AddReference(uid, "pvc", claimName, LinkMounts, detail)
+	}
+	// generic ephemeral volumes are backed by a claim named after the pod
+	// and the volume
+	if _, ok := volume["ephemeral"]; ok && podName != "" {
+		graph.AddReference(uid, "pvc", podName+"-"+unstructGetString(volume, "name"), LinkMounts, detail)
+	}
+	if cmName := unstructGetString(volume, "configMap", "name"); cmName != "" {
+		graph.AddReference(uid, "cm", cmName, LinkMounts, detail)
+	}
+	if secretName := unstructGetString(volume, "secret", "secretName"); secretName != "" {
+		graph.AddReference(uid, "secret", secretName, LinkMounts, detail)
+	}
+
+	for _, s := range unstructGetList(volume, "projected", "sources") {
+		source, ok := s.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		if cmName := unstructGetString(source, "configMap", "name"); cmName != "" {
+			graph.AddReference(uid, "cm", cmName, LinkMounts, detail)
+		}
+		if secretName := unstructGetString(source, "secret", "name"); secretName != "" {
+			graph.AddReference(uid, "secret", secretName, LinkMounts, detail)
+		}
+	}
+
+	if secretName := unstructGetString(volume, "csi", "nodePublishSecretRef", "name"); secretName != "" {
+		graph.AddReference(uid, "secret", secretName, LinkMounts, detail)
+	}
+	if secretName := unstructGetString(volume, "azureFile", "secretName"); secretName != "" {
+		graph.AddReference(uid, "secret", secretName, LinkMounts, detail)
+	}
+	for _, plugin := range secretRefVolumes {
+		if secretName := unstructGetString(volume, plugin, "secretRef", "name"); secretName != "" {
+			graph.AddReference(uid, "secret", secretName, LinkMounts, detail)
+		}
+	}
+}
+
+// mountDetail returns the containers mounting a volume and the volume name
+// as a link detail, e.g. "app,sidecar: config".
+func mountDetail(containers []string, volumeName string) string {
+	if len(containers) == 0 {
+		return volumeName
+	}
+	return fmt.Sprintf("%s: %s", strings.Join(containers, ","), volumeName)
+}
+
+// details of links from services to the pods their selector matches
+const (
+	SelectsReady    = "ready"
+	SelectsNotReady = "not ready"
+)
+
+// Services are linked to the pods their selector matches, so that the
+// relationship is visible even if no pod is ready. Pods that are not ready
+// endpoints of the service get a different link detail.
+func serviceLinks(graph *Graph, item unstructured.Unstructured) {
+	uid := string(item.GetUID())
+
+	selectorMap := unstructGetMap(item.Object, "spec", "selector")
+	if len(selectorMap) == 0 {
+		// services without selectors have manually managed endpoints
+		return
+	}
+	selector, err := unstructSelector(selectorMap)
+	if err != nil {
+		return
+	}
+
+	ready := readyEndpointPods(graph, item.GetName())
+	for _, poduid := range graph.FindBySelector("pod", selector) {
+		pod := graph.nodeMap[poduid]
+		if _, ok := ready[pod.Name]; ok {
+			graph.AddLink(uid, poduid, LinkSelects, SelectsReady)
+		} else {
+			graph.AddLink(uid, poduid, LinkSelects, SelectsNotReady)
+		}
+	}
+}
+
+// readyEndpointPods returns the names of the pods that are ready endpoints in
+// the EndpointSlices of a service.
+func readyEndpointPods(graph *Graph, service string) map[string]struct{} {
+	pods := make(map[string]struct{})
+	selector := labels.SelectorFromSet(labels.Set{"kubernetes.io/service-name": service})
+
+	for _, esuid := range graph.FindBySelector("endpointslice", selector) {
+		es := graph.nodeMap[esuid]
+		for _, e := range unstructGetList(es.Object, "endpoints") {
+			endpoint, ok := e.(map[string]interface{})
+			if !ok {
+				continue
+			}
+			if unstructGetString(endpoint, "targetRef", "kind") != "Pod" {
+				continue
+			}
+			// a missing ready condition is interpreted as ready
+			if ready, ok := unstructGetMap(endpoint, "conditions")["ready"].(bool); ok && !ready {
+				continue
+			}
+			pods[unstructGetString(endpoint, "targetRef", "name")] = struct{}{}
+		}
+	}
+
+	return pods
+}
+
+func routeLinks(graph *Graph, item unstructured.Unstructured) {
+	uid := string(item.GetUID())
+
+	to := unstructGetMap(item.Object, "spec", "to")
+	if to != nil {
+		kind := unstructGetString(to, "kind")
+		if kind == "Service" {
+			name := unstructGetString(to, "name")
+			if name != "" {
+				graph.AddReference(uid, "svc", name, LinkRoutesTo, weightDetail(to))
+			}
+		}
+	}
+
+	altBackends := unstructGetList(item.Object, "spec", "alternateBackends")
+	if len(altBackends) > 0 {
+		for _, b := range altBackends {
+			backend, ok := b.(map[string]interface{})
+			if !ok {
+				continue
+			}
+			kind := unstructGetString(backend, "kind")
+			if kind != "Service" {
+				continue
+			}
+			name := unstructGetString(backend, "name")
+			if name == "" {
+				continue
+			}
+			graph.AddReference(uid, "svc", name, LinkRoutesTo, weightDetail(backend))
+		}
+	}
+}
+
+// PodDisruptionBudgets are linked to the pods they protect.
+func pdbLinks(graph *Graph, item unstructured.Unstructured) {
+	selectorMap, ok := unstructGetMap(item.Object, "spec")["selector"].(map[string]interface{})
+	if !ok {
+		return
+	}
+	// an empty selector matches every pod in the namespace
+	selector, err := unstructLabelSelector(selectorMap)
+	if err != nil {
+		return
+	}
+
+	uid := string(item.GetUID())
+	for _, poduid := range graph.FindBySelector("pod", selector) {
+		graph.AddLink(uid, poduid, LinkSelects, "")
+	}
+}
+
+// pdbSummary describes the health of the pods protected by a
+// PodDisruptionBudget, e.g. "3 pods, 3 healthy (2 desired), 1 disruption
+// allowed".
+func pdbSummary(item unstructured.Unstructured) string {
+	return fmt.Sprintf("%s pods, %s healthy (%s desired), %s disruptions allowed",
+		valueOr(unstructGetScalar(item.Object, "status", "expectedPods"), "?"),
+		valueOr(unstructGetScalar(item.Object, "status", "currentHealthy"), "?"),
+		valueOr(unstructGetScalar(item.Object, "status", "desiredHealthy"), "?"),
+		valueOr(unstructGetScalar(item.Object, "status", "disruptionsAllowed"), "?"))
+}
+
+// Ingresses are linked to their backend services, TLS secrets and
+// IngressClass, similar to routes.
+func ingressLinks(graph *Graph, item unstructured.Unstructured) {
+	uid := string(item.GetUID())
+
+	// networking.k8s.io/v1beta1 calls it .spec.backend
+	defaultBackend := unstructGetMap(item.Object, "spec", "defaultBackend")
+	if defaultBackend == nil {
+		defaultBackend = unstructGetMap(item.Object, "spec", "backend")
+	}
+	if name := ingressBackendService(defaultBackend); name != "" {
+		graph.AddReference(uid, "svc", name, LinkRoutesTo, "default backend")
+	}
+
+	for _, r := range unstructGetList(item.Object, "spec", "rules") {
+		rule, ok := r.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		host := unstructGetString(rule, "host")
+		for _, p := range unstructGetList(rule, "http", "paths") {
+			path, ok := p.(map[string]interface{})
+			if !ok {
+				continue
+			}
+			name := ingressBackendService(unstructGetMap(path, "backend"))
+			if name == "" {
+				continue
+			}
+			graph.AddReference(uid, "svc", name, LinkRoutesTo, host+unstructGetString(path, "path"))
+		}
+	}
+
+	for _, t := range unstructGetList(item.Object, "spec", "tls") {
+		tls, ok := t.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		secretName := unstructGetString(tls, "secretName")
+		if secretName == "" {
+			continue
+		}
+		graph.AddReference(uid, "secret", secretName, LinkTLS, "")
+	}
+
+	className := unstructGetString(item.Object, "spec", "ingressClassName")
+	if className == "" {
+		className = unstructGetString(item.Object, "metadata", "annotations", "kubernetes.io/ingress.class")
+	}
+	if className != "" {
+		graph.AddReference(uid, "ingressclass", className, LinkClass, "")
+		return
+	}
+
+	// ingresses without a class are handled by the default IngressClass
+	for _, node := range graph.Nodes {
+		if node.Kind != "ingressclass" {
+			continue
+		}
+		if unstructGetString(node.Object, "metadata", "annotations", "ingressclass.kubernetes.io/is-default-class") == "true" {
+			graph.AddLink(uid, node.Uid, LinkClass, "default")
+		}
+	}
+}
+
+// ingressBackendService returns the name of the service of an ingress
+// backend in either the v1 or the v1beta1 format.
+func ingressBackendService(backend map[string]interface{}) string {
+	if backend == nil {
+		return ""
+	}
+	if name := unstructGetString(backend, "service", "name"); name != "" {
+		return name
+	}
+	return unstructGetString(backend, "serviceName")
+}
+
+// Gateways are linked to their GatewayClass and to the secrets holding the
+// certificates of their listeners. Gateways are listed in all namespaces so
+// that routes can attach to gateways in other namespaces.
+func gatewayLinks(graph *Graph, item unstructured.Unstructured) {
+	uid := string(item.GetUID())
+
+	className := unstructGetString(item.Object, "spec", "gatewayClassName")
+	if className != "" {
+		graph.AddReference(uid, "gatewayclass", className, LinkClass, "")
+	}
+
+	// secrets are only graphed for the namespace
+	if item.GetNamespace() != graph.namespace {
+		return
+	}
+	for _, l := range unstructGetList(item.Object, "spec", "listeners") {
+		listener, ok := l.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		for _, c := range unstructGetList(listener, "tls", "certificateRefs") {
+			ref, ok := c.(map[string]interface{})
+			if !ok {
+				continue
+			}
+			if !gatewayRefIs(ref, "", "Secret") {
+				continue
+			}
+			name := unstructGetString(ref, "name")
+			if name == "" || gatewayRefNamespace(ref, item.GetNamespace()) != item.GetNamespace() {
+				continue
+			}
+			graph.AddReference(uid, "secret", name, LinkTLS, unstructGetString(listener, "name"))
+		}
+	}
+}
+
+// HTTPRoutes, GRPCRoutes and TLSRoutes are linked to the gateways they attach
+// to and to their backend services, similar to routes.
+func gatewayRouteLinks(graph *Graph, item unstructured.Unstructured) {
+	uid := string(item.GetUID())
+	namespace := item.GetNamespace()
+
+	for _, p := range unstructGetList(item.Object, "spec", "parentRefs") {
+		ref, ok := p.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		if !gatewayRefIs(ref, "gateway.networking.k8s.io", "Gateway") {
+			continue
+		}
+		name := unstructGetString(ref, "name")
+		if name == "" {
+			continue
+		}
+		if ns := gatewayRefNamespace(ref, namespace); ns != namespace {
+			name = qualifiedName(ns, name)
+		}
+		graph.AddReference(uid, "gateway", name, LinkParent, unstructGetString(ref, "sectionName"))
+	}
+
+	for _, r := range unstructGetList(item.Object, "spec", "rules") {
+		rule, ok := r.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		for _, b := range unstructGetList(rule, "backendRefs") {
+			ref, ok := b.(map[string]interface{})
+			if !ok {
+				continue
+			}
+			if !gatewayRefIs(ref, "", "Service") {
+				continue
+			}
+			// services in other namespaces are not graphed
+			name := unstructGetString(ref, "name")
+			if name == "" || gatewayRefNamespace(ref, namespace) != namespace {
+				continue
+			}
+			graph.AddReference(uid, "svc", name, LinkRoutesTo, weightDetail(ref))
+		}
+	}
+}
+
+// gatewayRefIs returns true if a Gateway API object reference points to the
+// given kind, applying the defaults of the reference's group and kind.
+func gatewayRefIs(ref map[string]interface{}, group, kind string) bool {
+	refGroup, ok := ref["group"].(string)
+	if !ok {
+		refGroup = group
+	}
+	refKind, ok := ref["kind"].(string)
+	if !ok {
+		refKind = kind
+	}
+	return refGroup == group && refKind == kind
+}
+
+// gatewayRefNamespace returns the namespace of a Gateway API object
+// reference, which defaults to the namespace of the referring object.
+func gatewayRefNamespace(ref map[string]interface{}, namespace string) string {
+	if ns := unstructGetString(ref, "namespace"); ns != "" {
+		return ns
+	}
+	return namespace
+}
+
+func endpointSliceLinks(graph *Graph, item unstructured.Unstructured) {
+	esuid := string(item.GetUID())
+
+	endpoints := unstructGetList(item.Object, "endpoints")
+	if len(endpoints) > 0 {
+		for _, e := range endpoints {
+			endpoint, ok := e.(map[string]interface{})
+			if !ok {
+				continue
+			}
+			targetRef := unstructGetMap(endpoint, "targetRef")
+			if targetRef == nil {
+				continue
+			}
+			kind := unstructGetString(targetRef, "kind")
+			if kind != "Pod" {
+				continue
+			}
+			podName := unstructGetString(targetRef, "name")
+			if podName == "" {
+				continue
+			}
+			graph.AddReference(esuid, "pod", podName, LinkEndpoint, "")
+		}
+	}
+}
+
+// PersistentVolumeClaims are linked to the PersistentVolume they are bound
+// to, to their StorageClass while they wait for a volume to be provisioned,
+// and to the snapshot or claim they are populated from.
+func pvcLinks(graph *Graph, item unstructured.Unstructured) {
+	uid := string(item.GetUID())
+
+	if volumeName := unstructGetString(item.Object, "spec", "volumeName"); volumeName != "" {
+		graph.AddReference(uid, "pv", volumeName, LinkVolume, "")
+	} else if className := unstructGetString(item.Object, "spec", "storageClassName"); className != "" {
+		graph.AddReference(uid, "storageclass", className, LinkClass, "")
+	}
+
+	dataSource := unstructGetMap(item.Object, "spec", "dataSource")
+	name := unstructGetString(dataSource, "name")
+	if name == "" {
+		return
+	}
+	switch unstructGetString(dataSource, "kind") {
+	case "VolumeSnapshot":
+		graph.AddReference(uid, "volumesnapshot", name, LinkSource, "")
+	case "PersistentVolumeClaim":
+		graph.AddReference(uid, "pvc", name, LinkSource, "")
+	}
+}
+
+// PersistentVolumes are linked to their StorageClass.
+func pvLinks(graph *Graph, item unstructured.Unstructured) {
+	if className := unstructGetString(item.Object, "spec", "storageClassName"); className != "" {
+		graph.AddReference(string(item.GetUID()), "storageclass", className, LinkClass, "")
+	}
+}
+
+// VolumeSnapshots are linked to the claim they were taken from.
+func volumeSnapshotLinks(graph *Graph, item unstructured.Unstructured) {
+	if name := unstructGetString(item.Object, "spec", "source", "persistentVolumeClaimName"); name != "" {
+		graph.AddReference(string(item.GetUID()), "pvc", name, LinkSource, "")
+	}
+}
+
+// volumeSummary describes the phase, capacity and access modes of a
+// PersistentVolumeClaim or PersistentVolume, e.g. "Bound, 10Gi, RWO".
+func volumeSummary(item unstructured.Unstructured) string {
+	parts := []string{}
+	if phase := unstructGetString(item.Object, "status", "phase"); phase != "" {
+		parts = append(parts, phase)
+	}
+	// claims only have a capacity once they are bound
+	capacity := unstructGetString(item.Object, "status", "capacity", "storage")
+	if capacity == "" {
+		capacity = unstructGetString(item.Object, "spec", "capacity", "storage")
+	}
+	if capacity == "" {
+		if requested := unstructGetString(item.Object, "spec", "resources", "requests", "storage"); requested != "" {
+			capacity = requested + " requested"
+		}
+	}
+	if capacity != "" {
+		parts = append(parts, capacity)
+	}
+	modes := []string{}
+	for _, mode := range unstructGetStrings(item.Object, "spec", "accessModes") {
+		modes = append(modes, valueOr(accessModes[mode], mode))
+	}
+	if len(modes) > 0 {
+		parts = append(parts, strings.Join(modes, ","))
+	}
+	if policy := unstructGetString(item.Object, "spec", "persistentVolumeReclaimPolicy"); policy != "" {
+		parts = append(parts, policy)
+	}
+	return strings.Join(parts, ", ")
+}
+
+// abbreviations of access modes as shown by kubectl
+var accessModes = map[string]string{
+	"ReadWriteOnce":    "RWO",
+	"ReadOnlyMany":     "ROX",
+	"ReadWriteMany":    "RWX",
+	"ReadWriteOncePod": "RWOP",
+}
+
+// storageClassSummary describes the provisioner and reclaim policy of a
+// StorageClass, e.g. "ebs.csi.aws.com, Delete, default".
+func storageClassSummary(item unstructured.Unstructured) string {
+	parts := []string{unstructGetString(item.Object, "provisioner")}
+	parts = append(parts, valueOr(unstructGetString(item.Object, "reclaimPolicy"), "Delete"))
+	if unstructGetString(item.Object, "metadata", "annotations", "storageclass.kubernetes.io/is-default-class") == "true" {
+		parts = append(parts, "default")
+	}
+	return strings.Join(parts, ", ")
+}
+
+// volumeSnapshotSummary describes whether a VolumeSnapshot is ready and its
+// size, e.g. "ready, 10Gi".
+func volumeSnapshotSummary(item unstructured.Unstructured) string {
+	state := "not ready"
+	if ready, _ := unstructGetMap(item.Object, "status")["readyToUse"].(bool); ready {
+		state = "ready"
+	}
+	if size := unstructGetScalar(item.Object, "status", "restoreSize"); size != "" {
+		return fmt.Sprintf("%s, %s", state, size)
+	}
+	return state
+}
+
+// pullSecretNames returns the names of the imagePullSecrets of a pod spec or
+// a service account.
+func pullSecretNames(obj map[string]interface{}) []string {
+	names := []string{}
+	for _, s := range unstructGetList(obj, "imagePullSecrets") {
+		secret, ok := s.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		if name := unstructGetString(secret, "name"); name != "" {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// kinds of the resources that can be scaled by a HorizontalPodAutoscaler
+var scaleTargetKinds = map[string]string{
+	"Deployment":            "deployment",
+	"StatefulSet":           "sts",
+	"DeploymentConfig":      "dc",
+	"ReplicaSet":            "replicaset",
+	"ReplicationController": "rc",
+}
+
+// HorizontalPodAutoscalers are linked to the workload they scale.
+func hpaLinks(graph *Graph, item unstructured.Unstructured) {
+	target := unstructGetMap(item.Object, "spec", "scaleTargetRef")
+	kind, ok := scaleTargetKinds[unstructGetString(target, "kind")]
+	if !ok {
+		return
+	}
+	name := unstructGetString(target, "name")
+	if name == "" {
+		return
+	}
+	graph.AddReference(string(item.GetUID()), kind, name, LinkScales, "")
+}
+
+// hpaSummary describes the replicas and metrics of a HorizontalPodAutoscaler,
+// e.g. "replicas 2/3 (min 1, max 5), cpu 45%/80%, ScalingLimited:
+// TooManyReplicas".
+func hpaSummary(item unstructured.Unstructured) string {
+	minReplicas := unstructGetScalar(item.Object, "spec", "minReplicas")
+	if minReplicas == "" {
+		minReplicas = "1"
+	}
+	parts := []string{fmt.Sprintf("replicas %s/%s (min %s, max %s)",
+		valueOr(unstructGetScalar(item.Object, "status", "currentReplicas"), "?"),
+		valueOr(unstructGetScalar(item.Object, "status", "desiredReplicas"), "?"),
+		minReplicas,
+		valueOr(unstructGetScalar(item.Object, "spec", "maxReplicas"), "?"))}
+
+	// autoscaling/v1 only supports cpu utilization
+	if target := unstructGetScalar(item.Object, "spec", "targetCPUUtilizationPercentage"); target != "" {
+		current := unstructGetScalar(item.Object, "status", "currentCPUUtilizationPercentage")
+		parts = append(parts, fmt.Sprintf("cpu %s%%/%s%%", valueOr(current, "?"), target))
+	}
+
+	current := make(map[string]string)
+	for _, m := range unstructGetList(item.Object, "status", "currentMetrics") {
+		metric, ok := m.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		name, status := hpaMetric(metric)
+		current[name] = metricValue(unstructGetMap(status, "current"))
+	}
+	for _, m := range unstructGetList(item.Object, "spec", "metrics") {
+		metric, ok := m.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		name, spec := hpaMetric(metric)
+		if name == "" {
+			continue
+		}
+		parts = append(parts, fmt.Sprintf("%s %s/%s", name, valueOr(current[name], "?"), metricValue(unstructGetMap(spec, "target"))))
+	}
+
+	// report conditions that keep the autoscaler from scaling
+	for _, c := range unstructGetList(item.Object, "status", "conditions") {
+		condition, ok := c.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		conditionType := unstructGetString(condition, "type")
+		status := unstructGetString(condition, "status")
+		if (conditionType == "ScalingLimited" && status == "True") ||
+			((conditionType == "AbleToScale" || conditionType == "ScalingActive") && status == "False") {
+			parts = append(parts, fmt.Sprintf("%s: %s", conditionType, unstructGetString(condition, "reason")))
+		}
+	}
+
+	return strings.Join(parts, ", ")
+}
+
+// hpaMetric returns the name of an autoscaling/v2 metric and the source
+// holding its target or current value.
+func hpaMetric(metric map[string]interface{}) (string, map[string]interface{}) {
+	switch unstructGetString(metric, "type") {
+	case "Resource":
+		source := unstructGetMap(metric, "resource")
+		return unstructGetString(source, "name"), source
+	case "ContainerResource":
+		source := unstructGetMap(metric, "containerResource")
+		return fmt.Sprintf("%s/%s", unstructGetString(source, "container"), unstructGetString(source, "name")), source
+	case "Pods":
+		source := unstructGetMap(metric, "pods")
+		return unstructGetString(source, "metric", "name"), source
+	case "Object":
+		source := unstructGetMap(metric, "object")
+		return unstructGetString(source, "metric", "name"), source
+	case "External":
+		source := unstructGetMap(metric, "external")
+		return unstructGetString(source, "metric", "name"), source
+	default:
+		return "", nil
+	}
+}
+
+// metricValue formats an autoscaling/v2 MetricTarget or MetricValueStatus.
+func metricValue(value map[string]interface{}) string {
+	if utilization := unstructGetScalar(value, "averageUtilization"); utilization != "" {
+		return utilization + "%"
+	}
+	if average := unstructGetScalar(value, "averageValue"); average != "" {
+		return average
+	}
+	return unstructGetScalar(value, "value")
+}
+
+func valueOr(value, fallback string) string {
+	if value == "" {
+		return fallback
+	}
+	return value
+}
+
+func envKeyDetail(container, key string) string {
+	if key == "" {
+		return container
+	}
+	return fmt.Sprintf("%s: %s", container, key)
+}
+
+// weightDetail returns the weight of a backend as a link detail.
+func weightDetail(backend map[string]interface{}) string {
+	weight, ok := backend["weight"]
+	if !ok {
+		return ""
+	}
+	return fmt.Sprintf("weight %v", weight)
+}
+
+func addOwnerLinks(u unstructured.Unstructured, graph *Graph) {
+	for _, owner := range unstructGetOwners(u) {
+		graph.AddLink(owner, string(u.GetUID()), LinkOwns, "")
+	}
+}
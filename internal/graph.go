@@ -5,45 +5,115 @@ import (
 	"encoding/json"
 	"fmt"
 	"log"
+	"sort"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/labels"
 )
 
+// status of placeholder nodes for referenced resources that do not exist
+const NodeStatusMissing = "missing"
+
 type Node struct {
-	Uid    string                 `json:"id"`
-	Kind   string                 `json:"kind"`
-	Name   string                 `json:"name"`
-	Object map[string]interface{} `json:"object"`
+	Uid     string                 `json:"id"`
+	Kind    string                 `json:"kind"`
+	Name    string                 `json:"name"`
+	Object  map[string]interface{} `json:"object"`
+	Status  string                 `json:"status,omitempty"`
+	Summary string                 `json:"summary,omitempty"` // short description of the resource's state
 }
 
+// relationship types of links
+const (
+	LinkOwns       = "owns"       // owner reference
+	LinkMounts     = "mounts"     // volume
+	LinkEnvFrom    = "envFrom"    // all keys exposed as environment variables
+	LinkEnvKeyRef  = "envKeyRef"  // single key exposed as an environment variable
+	LinkSelects    = "selects"    // label selector
+	LinkRoutesTo   = "routesTo"   // ingress / route backend
+	LinkEndpoint   = "endpoint"   // endpoint slice to pod
+	LinkPullsImage = "pullsImage" // container image
+	LinkPullSecret = "pullSecret" // registry credentials
+	LinkResolvesTo = "resolvesTo" // image reference to the digest it was pulled as
+	LinkProduces   = "produces"   // build output
+	LinkTrigger    = "trigger"    // image stream tag whose changes trigger a build or rollout
+	LinkBuildInput = "buildInput" // builder image, credentials and other inputs of builds
+	LinkTLS        = "tls"        // TLS certificate secret
+	LinkClass      = "class"      // IngressClass and similar class resources
+	LinkParent     = "parent"     // gateway API route to the gateway it attaches to
+	LinkScales     = "scales"     // autoscaler to its scale target
+	LinkTraffic    = "traffic"    // network traffic allowed by network policies
+	LinkRunsAs     = "runsAs"     // pod to its service account
+	LinkToken      = "token"      // service account to its token secret
+	LinkSubject    = "subject"    // role binding to a service account it binds
+	LinkRoleRef    = "roleRef"    // role binding to the role it grants
+	LinkVolume     = "volume"     // persistent volume claim to its volume
+	LinkSource     = "source"     // snapshot or volume to the volume it was created from
+	LinkReferences = "references" // any other reference
+)
+
 type Link struct {
 	Source string `json:"source"`
 	Target string `json:"target"`
+	Type   string `json:"type"`
+	Detail string `json:"detail,omitempty"` // e.g. container name, key or weight
+}
+
+// Reference is a link to a node that is identified by kind and name. It is
+// resolved once all nodes have been added to the graph.
+type Reference struct {
+	Source     string `json:"source"`
+	TargetKind string `json:"targetkind"`
+	TargetName string `json:"targetname"`
+	Type       string `json:"type"`
+	Detail     string `json:"detail,omitempty"`
 }
 
 type Graph struct {
 	nodeMap     map[string]*Node    // map of uid to node
 	nameMap     map[string]*Node    // map of name to node
-	linkMap     map[string]struct{} // key is in the form source:target
+	linkMap     map[string]int      // key is in the form source:target:type, value is the index in Links
 	linkSources map[string]struct{} // key is the source uid
 	linkTargets map[string]struct{} // key is the target uid
 	Nodes       []*Node             `json:"nodes"`
 	Links       []Link              `json:"links"`
+	Warnings    []Warning           `json:"warnings"`
+	pending     []Reference         // references waiting to be resolved
+	pendingMap  map[Reference]struct{}
+	loadedKinds map[string]struct{} // kinds whose resources were listed successfully
+	externalUid map[string]string   // uids of nodes outside the namespace to their namespace
+	namespace   string              // namespace being graphed
+	Unresolved  []Reference         `json:"unresolved"` // references to nodes that do not exist
 }
 
 func InitGraph() *Graph {
 	graph := Graph{
 		nodeMap:     make(map[string]*Node),
 		nameMap:     make(map[string]*Node),
-		linkMap:     map[string]struct{}{},
+		linkMap:     map[string]int{},
 		linkSources: map[string]struct{}{},
 		linkTargets: map[string]struct{}{},
 		Nodes:       []*Node{},
 		Links:       []Link{},
+		Warnings:    []Warning{},
+		pending:     []Reference{},
+		pendingMap:  map[Reference]struct{}{},
+		loadedKinds: map[string]struct{}{},
+		externalUid: map[string]string{},
+		Unresolved:  []Reference{},
 	}
 
 	return &graph
 }
 
-func (g *Graph) addNode(uid, kind, name string, obj map[string]interface{}) {
+// Namespace returns the namespace being graphed.
+func (g *Graph) Namespace() string {
+	return g.namespace
+}
+
+// AddNode adds a node for a resource. obj is the resource's content, which
+// is used to match label selectors and may be nil.
+func (g *Graph) AddNode(uid, kind, name string, obj map[string]interface{}) {
 	n := Node{
 		Uid:    uid,
 		Kind:   kind,
@@ -55,44 +125,165 @@ func (g *Graph) addNode(uid, kind, name string, obj map[string]interface{}) {
 	g.Nodes = append(g.Nodes, &n)
 }
 
+// markExternal records that a node is not part of the namespace, either
+// because it is cluster-scoped or because it is in another namespace. Such
+// nodes are only kept if they are linked to the namespace's resources. The
+// namespace is empty for cluster-scoped nodes.
+func (g *Graph) markExternal(uid, namespace string) {
+	g.externalUid[uid] = namespace
+}
+
+// externalNamespaces returns the other namespaces that kept nodes are in.
+func (g *Graph) externalNamespaces() []string {
+	seen := make(map[string]struct{})
+	namespaces := []string{}
+	for uid, ns := range g.externalUid {
+		if _, ok := g.nodeMap[uid]; !ok || ns == "" {
+			continue
+		}
+		if _, ok := seen[ns]; ok {
+			continue
+		}
+		seen[ns] = struct{}{}
+		namespaces = append(namespaces, ns)
+	}
+	sort.Strings(namespaces)
+	return namespaces
+}
+
 func (g *Graph) nodeExists(uid string) bool {
 	_, ok := g.nodeMap[uid]
 	return ok
 }
 
-func (g *Graph) addLink(source, target string) {
-	if g.linkExists(source, target) {
+// AddLink adds a link of the given type. Nodes are only linked once per
+// type - the details of further links of the same type are added to the
+// detail of the existing link, separated by commas.
+func (g *Graph) AddLink(source, target, linkType, detail string) {
+	key := linkMapKey(source, target, linkType)
+	if i, ok := g.linkMap[key]; ok {
+		g.Links[i].Detail = mergeDetails(g.Links[i].Detail, detail)
 		return
 	}
 	l := Link{
 		Source: source,
 		Target: target,
+		Type:   linkType,
+		Detail: detail,
 	}
+	g.linkMap[key] = len(g.Links)
 	g.Links = append(g.Links, l)
-	g.linkMap[linkMapKey(source, target)] = struct{}{}
 	g.linkSources[source] = struct{}{}
 	g.linkTargets[target] = struct{}{}
 }
 
+// mergeDetails adds a detail to the comma-separated details of a link unless
+// it is empty or already present.
+func mergeDetails(details, detail string) string {
+	if detail == "" {
+		return details
+	}
+	if details == "" {
+		return detail
+	}
+	for _, d := range strings.Split(details, ", ") {
+		if d == detail {
+			return details
+		}
+	}
+	return details + ", " + detail
+}
+
+// AddReference records a link from source to the node of the given kind and
+// name, to be resolved by resolveReferences.
+func (g *Graph) AddReference(source, kind, name, linkType, detail string) {
+	ref := Reference{
+		Source:     source,
+		TargetKind: kind,
+		TargetName: name,
+		Type:       linkType,
+		Detail:     detail,
+	}
+	if _, ok := g.pendingMap[ref]; ok {
+		return
+	}
+	g.pendingMap[ref] = struct{}{}
+	g.pending = append(g.pending, ref)
+}
+
+// kinds whose nodes are derived from the items of another kind rather than
+// listed, e.g. ImageStreamTags from the tags of ImageStreams
+var derivedKinds = map[string][]string{
+	"is": {"istag"},
+}
+
+// markLoaded records that all resources of a kind are in the graph, so that
+// references to resources of that kind that cannot be found are dangling.
+func (g *Graph) markLoaded(kind string) {
+	g.loadedKinds[kind] = struct{}{}
+	for _, derived := range derivedKinds[kind] {
+		g.loadedKinds[derived] = struct{}{}
+	}
+}
+
+// resolveReferences turns pending references into links. References to
+// nodes that do not exist are kept in Unresolved, and are linked to a
+// placeholder node if addMissing is true. References to kinds that have not
+// been loaded are dropped as we cannot tell if they are dangling, and so are
+// dangling references from external nodes, which are not the namespace's
+// problem.
+func (g *Graph) resolveReferences(addMissing bool) {
+	for _, ref := range g.pending {
+		uid := g.FindResource(ref.TargetKind, ref.TargetName)
+		if uid == "" {
+			if _, ok := g.loadedKinds[ref.TargetKind]; !ok {
+				continue
+			}
+			if _, ok := g.externalUid[ref.Source]; ok {
+				continue
+			}
+			g.Unresolved = append(g.Unresolved, ref)
+			if !addMissing {
+				continue
+			}
+			uid = missingUid(ref.TargetKind, ref.TargetName)
+			if !g.nodeExists(uid) {
+				g.AddNode(uid, ref.TargetKind, ref.TargetName, nil)
+				g.nodeMap[uid].Status = NodeStatusMissing
+			}
+		}
+		g.AddLink(ref.Source, uid, ref.Type, ref.Detail)
+	}
+	g.pending = []Reference{}
+	g.pendingMap = map[Reference]struct{}{}
+}
+
+func (g *Graph) addWarning(w Warning) {
+	g.Warnings = append(g.Warnings, w)
+}
+
 func (g *Graph) cleanLinks() {
 	cleaned := []Link{}
 
 	for _, link := range g.Links {
 		if !g.nodeExists(link.Source) || !g.nodeExists(link.Target) {
-			delete(g.linkMap, linkMapKey(link.Source, link.Target))
+			delete(g.linkMap, linkMapKey(link.Source, link.Target, link.Type))
 			delete(g.linkSources, link.Source)
 			delete(g.linkTargets, link.Target)
 			continue
 		}
+		g.linkMap[linkMapKey(link.Source, link.Target, link.Type)] = len(cleaned)
 		cleaned = append(cleaned, link)
 	}
 	g.Links = cleaned
 }
 
-// Cleans out ConfigMaps and Secrets that are not linked to anything else in
-// order to avoid cluttering the graph
+// Cleans out ConfigMaps and Secrets that are not linked to anything else,
+// and external nodes that cannot be reached from any node in the namespace,
+// in order to avoid cluttering the graph
 func (g *Graph) cleanNodes() {
 	cleaned := []*Node{}
+	reachable := g.reachableExternalNodes()
 
 	for _, node := range g.Nodes {
 		if node.Kind == "cm" || node.Kind == "secret" {
@@ -108,17 +299,61 @@ func (g *Graph) cleanNodes() {
 				}
 			}
 		}
+		if _, isExternal := g.externalUid[node.Uid]; isExternal {
+			if _, ok := reachable[node.Uid]; !ok {
+				delete(g.nodeMap, node.Uid)
+				delete(g.nameMap, nodeTitle(node.Kind, node.Name))
+				continue
+			}
+		}
 		cleaned = append(cleaned, node)
 	}
 	g.Nodes = cleaned
 }
 
-func (g *Graph) linkExists(source, target string) bool {
-	_, ok := g.linkMap[linkMapKey(source, target)]
-	return ok
+// reachableExternalNodes returns the uids of the external nodes that are
+// linked to a node in the namespace in either direction, or that are the
+// target of a link from a reachable external node. Links into an external
+// node are not followed backwards, so that e.g. a ClusterRole bound in the
+// namespace does not pull in every other binding of the ClusterRole.
+func (g *Graph) reachableExternalNodes() map[string]struct{} {
+	targets := make(map[string][]string)
+	reachable := make(map[string]struct{})
+	queue := []string{}
+	for _, link := range g.Links {
+		targets[link.Source] = append(targets[link.Source], link.Target)
+		_, sourceExternal := g.externalUid[link.Source]
+		_, targetExternal := g.externalUid[link.Target]
+		switch {
+		case sourceExternal && !targetExternal:
+			reachable[link.Source] = struct{}{}
+			queue = append(queue, link.Source)
+		case targetExternal && !sourceExternal:
+			reachable[link.Target] = struct{}{}
+			queue = append(queue, link.Target)
+		}
+	}
+
+	for len(queue) > 0 {
+		uid := queue[0]
+		queue = queue[1:]
+		for _, next := range targets[uid] {
+			if _, ok := reachable[next]; ok {
+				continue
+			}
+			if _, ok := g.externalUid[next]; !ok {
+				continue
+			}
+			reachable[next] = struct{}{}
+			queue = append(queue, next)
+		}
+	}
+	return reachable
 }
 
-func (g *Graph) findResource(kind, name string) string {
+// FindResource returns the uid of the node of a kind with the given name, or
+// "" if there is no such node.
+func (g *Graph) FindResource(kind, name string) string {
 	node, ok := g.nameMap[nodeTitle(kind, name)]
 	if !ok {
 		return ""
@@ -126,6 +361,21 @@ func (g *Graph) findResource(kind, name string) string {
 	return node.Uid
 }
 
+// FindBySelector returns the uids of all nodes of a kind whose labels match
+// the selector.
+func (g *Graph) FindBySelector(kind string, selector labels.Selector) []string {
+	uids := []string{}
+	for _, node := range g.Nodes {
+		if node.Kind != kind {
+			continue
+		}
+		if selector.Matches(unstructGetLabels(node.Object)) {
+			uids = append(uids, node.Uid)
+		}
+	}
+	return uids
+}
+
 func (g Graph) String() string {
 	var b bytes.Buffer
 	enc := json.NewEncoder(&b)
@@ -135,8 +385,18 @@ func (g Graph) String() string {
 	return b.String()
 }
 
-func linkMapKey(source, target string) string {
-	return fmt.Sprintf("%s:%s", source, target)
+func linkMapKey(source, target, linkType string) string {
+	return fmt.Sprintf("%s:%s:%s", source, target, linkType)
+}
+
+// qualifiedName returns the name used for nodes of namespaced resources in
+// other namespaces.
+func qualifiedName(namespace, name string) string {
+	return fmt.Sprintf("%s/%s", namespace, name)
+}
+
+func missingUid(kind, name string) string {
+	return fmt.Sprintf("missing:%s", nodeTitle(kind, name))
 }
 
 func nodeTitle(kind, name string) string {
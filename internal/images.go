@@ -0,0 +1,186 @@
+package internal
+
+import (
+	"strings"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+// Images are graphed as two kinds of nodes of kind "image": a node per
+// digest, with the digest without the algorithm prefix as its uid, and a
+// node per normalized image reference (registry/repository:tag) for images
+// whose digest is not known. Pods are linked to the digest they run if the
+// container status reports it, and workload templates are linked to the
+// references they pull. References are linked to the digests they resolve
+// to, so that pods, templates and builds of the same image end up connected.
+
+// addImageNode adds an image node unless it already exists, e.g. because
+// another pod runs the same image, and returns whether it was added.
+func addImageNode(graph *Graph, uid, name string) bool {
+	if graph.nodeExists(uid) {
+		return false
+	}
+	graph.AddNode(uid, "image", name, nil)
+	return true
+}
+
+// the uid of the node of an image reference without a known digest
+func imageRefUid(ref string) string {
+	return "image:" + ref
+}
+
+// addImageRefNode adds the node of an image that is pulled by reference
+// rather than from a container, e.g. a DockerImage builder image.
+func addImageRefNode(graph *Graph, image string) {
+	ref, digest := normalizeImage(image)
+	if digest != "" {
+		addImageNode(graph, imageDigestUid(digest), imageDigestName(ref, digest))
+		return
+	}
+	addImageNode(graph, imageRefUid(ref), ref)
+}
+
+// imageRefNodeUid returns the uid of the node added by addImageRefNode.
+func imageRefNodeUid(image string) string {
+	ref, digest := normalizeImage(image)
+	if digest != "" {
+		return imageDigestUid(digest)
+	}
+	return imageRefUid(ref)
+}
+
+// podImageNodes adds the image nodes of a pod's containers.
+func podImageNodes(graph *Graph, item unstructured.Unstructured) {
+	podSpecImageNodes(graph, unstructGetMap(item.Object, "spec"), containerImageIDs(item.Object))
+}
+
+// templateImageNodes returns an ExtraNodes function that adds the image nodes
+// of the pod template at the given path.
+func templateImageNodes(path ...string) func(graph *Graph, item unstructured.Unstructured) {
+	return func(graph *Graph, item unstructured.Unstructured) {
+		podSpecImageNodes(graph, unstructGetMap(item.Object, path...), nil)
+	}
+}
+
+func podSpecImageNodes(graph *Graph, spec map[string]interface{}, imageIDs map[string]string) {
+	for _, field := range containerFields {
+		for _, c := range unstructGetList(spec, field) {
+			container, ok := c.(map[string]interface{})
+			if !ok {
+				continue
+			}
+			image := unstructGetString(container, "image")
+			if image == "" {
+				continue
+			}
+			ref, digest := normalizeImage(image)
+			if id := imageIDs[unstructGetString(container, "name")]; id != "" {
+				digest = id
+			}
+			if !strings.Contains(ref, "@") {
+				addImageNode(graph, imageRefUid(ref), ref)
+			}
+			if digest != "" {
+				addImageNode(graph, imageDigestUid(digest), imageDigestName(ref, digest))
+			}
+		}
+	}
+}
+
+// containerImageLinks links a resource to the image of one of its
+// containers. imageIDs holds the digests reported in the container statuses
+// of pods and is nil for templates.
+func containerImageLinks(graph *Graph, uid string, container map[string]interface{}, imageIDs map[string]string) {
+	image := unstructGetString(container, "image")
+	if image == "" {
+		return
+	}
+	containerName := unstructGetString(container, "name")
+	ref, digest := normalizeImage(image)
+	if id := imageIDs[containerName]; id != "" {
+		digest = id
+	}
+
+	if digest == "" {
+		graph.AddLink(uid, imageRefUid(ref), LinkPullsImage, containerName)
+		return
+	}
+	graph.AddLink(uid, imageDigestUid(digest), LinkPullsImage, containerName)
+	if !strings.Contains(ref, "@") {
+		graph.AddLink(imageRefUid(ref), imageDigestUid(digest), LinkResolvesTo, "")
+	}
+}
+
+// containerImageIDs maps the container names of a pod to the digests of the
+// images they run. Image IDs without a repository digest (e.g. images that
+// were loaded into the node rather than pulled) are ignored, as they are the
+// id of the image configuration rather than the digest of the manifest.
+func containerImageIDs(pod map[string]interface{}) map[string]string {
+	ids := make(map[string]string)
+	for _, field := range []string{"initContainerStatuses", "containerStatuses", "ephemeralContainerStatuses"} {
+		for _, s := range unstructGetList(pod, "status", field) {
+			status, ok := s.(map[string]interface{})
+			if !ok {
+				continue
+			}
+			imageID := unstructGetString(status, "imageID")
+			at := strings.LastIndex(imageID, "@")
+			if at == -1 {
+				continue
+			}
+			ids[unstructGetString(status, "name")] = imageID[at+1:]
+		}
+	}
+	return ids
+}
+
+// normalizeImage returns the fully qualified reference of an image, e.g.
+// docker.io/library/nginx:latest for nginx, and the digest the image is
+// pinned to, if any. References pinned to a digest without a tag are
+// returned as registry/repository@digest.
+func normalizeImage(image string) (ref, digest string) {
+	name := image
+	if at := strings.Index(image, "@"); at != -1 {
+		name, digest = image[:at], image[at+1:]
+	}
+
+	tag := ""
+	if colon := strings.LastIndex(name, ":"); colon > strings.LastIndex(name, "/") {
+		name, tag = name[:colon], name[colon+1:]
+	}
+
+	// the first component is a registry if it looks like a host name
+	domain, path := "docker.io", name
+	if slash := strings.Index(name, "/"); slash != -1 {
+		first := name[:slash]
+		if strings.ContainsAny(first, ".:") || first == "localhost" {
+			domain, path = first, name[slash+1:]
+		}
+	}
+	if domain == "index.docker.io" {
+		domain = "docker.io"
+	}
+	if domain == "docker.io" && !strings.Contains(path, "/") {
+		path = "library/" + path
+	}
+
+	switch {
+	case tag != "":
+		return domain + "/" + path + ":" + tag, digest
+	case digest != "":
+		return domain + "/" + path + "@" + digest, digest
+	default:
+		return domain + "/" + path + ":latest", digest
+	}
+}
+
+// digest nodes are named after the repository they were first seen in
+func imageDigestName(ref, digest string) string {
+	repository := ref
+	if at := strings.Index(ref, "@"); at != -1 {
+		repository = ref[:at]
+	} else if colon := strings.LastIndex(ref, ":"); colon > strings.LastIndex(ref, "/") {
+		repository = ref[:colon]
+	}
+	return repository + "@" + digest
+}
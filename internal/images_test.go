@@ -0,0 +1,50 @@
+package internal
+
+import "testing"
+
+func TestNormalizeImage(t *testing.T) {
+	tests := []struct {
+		image  string
+		ref    string
+		digest string
+	}{
+		{"nginx", "docker.io/library/nginx:latest", ""},
+		{"nginx:1.21", "docker.io/library/nginx:1.21", ""},
+		{"bitnami/redis", "docker.io/bitnami/redis:latest", ""},
+		{"docker.io/nginx", "docker.io/library/nginx:latest", ""},
+		{"index.docker.io/nginx:1.21", "docker.io/library/nginx:1.21", ""},
+		{"index.docker.io/bitnami/redis", "docker.io/bitnami/redis:latest", ""},
+		{"quay.io/org/app:v1", "quay.io/org/app:v1", ""},
+		{"localhost/app", "localhost/app:latest", ""},
+		{"localhost:5000/app", "localhost:5000/app:latest", ""},
+		{"registry:5000/team/app:v2", "registry:5000/team/app:v2", ""},
+		{"quay.io/org/app@sha256:abc", "quay.io/org/app@sha256:abc", "sha256:abc"},
+		{"nginx@sha256:abc", "docker.io/library/nginx@sha256:abc", "sha256:abc"},
+		{"registry:5000/team/app:v1@sha256:def", "registry:5000/team/app:v1", "sha256:def"},
+	}
+
+	for _, test := range tests {
+		ref, digest := normalizeImage(test.image)
+		if ref != test.ref || digest != test.digest {
+			t.Errorf("normalizeImage(%q) = %q, %q - expected %q, %q", test.image, ref, digest, test.ref, test.digest)
+		}
+	}
+}
+
+func TestImageDigestName(t *testing.T) {
+	tests := []struct {
+		ref    string
+		digest string
+		name   string
+	}{
+		{"docker.io/library/nginx:latest", "sha256:abc", "docker.io/library/nginx@sha256:abc"},
+		{"quay.io/org/app@sha256:abc", "sha256:abc", "quay.io/org/app@sha256:abc"},
+		{"registry:5000/team/app", "sha256:def", "registry:5000/team/app@sha256:def"},
+	}
+
+	for _, test := range tests {
+		if name := imageDigestName(test.ref, test.digest); name != test.name {
+			t.Errorf("imageDigestName(%q, %q) = %q - expected %q", test.ref, test.digest, name, test.name)
+		}
+	}
+}
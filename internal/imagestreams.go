@@ -0,0 +1,139 @@
+package internal
+
+import (
+	"fmt"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+// ImageStreamTags cannot be watched, so their nodes are derived from the
+// tags of the ImageStreams instead. Tag nodes are named imagestream:tag, and
+// namespace/imagestream:tag for image streams in other namespaces (e.g. the
+// builder images in the openshift namespace), which are only kept if
+// something in the namespace refers to them.
+
+// the uid of the node of an ImageStreamTag
+func imageStreamTagUid(namespace, imageStream, tag string) string {
+	return fmt.Sprintf("istag:%s/%s:%s", namespace, imageStream, tag)
+}
+
+// imageStreamNodes adds a node for every tag of an ImageStream and for the
+// image digests the tags point to.
+func imageStreamNodes(graph *Graph, item unstructured.Unstructured) {
+	namespace := item.GetNamespace()
+	external := namespace != graph.namespace
+	for _, tag := range imageStreamTags(item.Object) {
+		uid := imageStreamTagUid(namespace, item.GetName(), tag)
+		if graph.nodeExists(uid) {
+			continue
+		}
+		name := item.GetName() + ":" + tag
+		if external {
+			name = qualifiedName(namespace, name)
+			graph.markExternal(uid, namespace)
+		}
+		graph.AddNode(uid, "istag", name, nil)
+	}
+
+	repository := unstructGetString(item.Object, "status", "dockerImageRepository")
+	for _, t := range unstructGetList(item.Object, "status", "tags") {
+		tag, ok := t.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		digest := imageStreamTagDigest(tag)
+		if digest == "" {
+			continue
+		}
+		if addImageNode(graph, imageDigestUid(digest), imageDigestName(repository, digest)) && external {
+			graph.markExternal(imageDigestUid(digest), namespace)
+		}
+	}
+}
+
+// ImageStreams are linked to their tags, tags to the digest they currently
+// point to, and tags that track another tag to that tag.
+func imageStreamLinks(graph *Graph, item unstructured.Unstructured) {
+	uid := string(item.GetUID())
+	for _, tag := range imageStreamTags(item.Object) {
+		graph.AddLink(uid, imageStreamTagUid(item.GetNamespace(), item.GetName(), tag), LinkOwns, "")
+	}
+
+	for _, t := range unstructGetList(item.Object, "status", "tags") {
+		tag, ok := t.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		if digest := imageStreamTagDigest(tag); digest != "" {
+			graph.AddLink(imageStreamTagUid(item.GetNamespace(), item.GetName(), unstructGetString(tag, "tag")), imageDigestUid(digest), LinkResolvesTo, "")
+		}
+	}
+
+	for _, t := range unstructGetList(item.Object, "spec", "tags") {
+		tag, ok := t.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		from := unstructGetMap(tag, "from")
+		if target := imageStreamTagRef(graph, from, item.GetNamespace()); target != "" {
+			graph.AddReference(imageStreamTagUid(item.GetNamespace(), item.GetName(), unstructGetString(tag, "name")), "istag", target, LinkSource, "")
+		}
+	}
+}
+
+// imageStreamTags returns the names of the tags in the spec and the status
+// of an ImageStream.
+func imageStreamTags(imageStream map[string]interface{}) []string {
+	tags := []string{}
+	seen := make(map[string]struct{})
+	for _, field := range []struct{ list, name string }{{"spec", "name"}, {"status", "tag"}} {
+		for _, t := range unstructGetList(imageStream, field.list, "tags") {
+			tag, ok := t.(map[string]interface{})
+			if !ok {
+				continue
+			}
+			name := unstructGetString(tag, field.name)
+			if _, ok := seen[name]; ok || name == "" {
+				continue
+			}
+			seen[name] = struct{}{}
+			tags = append(tags, name)
+		}
+	}
+	return tags
+}
+
+// the digest of the most recent image of a status tag
+func imageStreamTagDigest(tag map[string]interface{}) string {
+	items := unstructGetList(tag, "items")
+	if len(items) == 0 {
+		return ""
+	}
+	item, ok := items[0].(map[string]interface{})
+	if !ok {
+		return ""
+	}
+	return unstructGetString(item, "image")
+}
+
+// imageStreamTagRef returns the node name of the ImageStreamTag that an
+// object reference in the given namespace points to, or "" if it points to
+// something else. References to an ImageStreamImage are not followed.
+func imageStreamTagRef(graph *Graph, ref map[string]interface{}, namespace string) string {
+	if unstructGetString(ref, "kind") != "ImageStreamTag" {
+		return ""
+	}
+	name := unstructGetString(ref, "name")
+	if name == "" {
+		return ""
+	}
+	if !strings.Contains(name, ":") {
+		// tags default to latest
+		name += ":latest"
+	}
+	if ns := valueOr(unstructGetString(ref, "namespace"), namespace); ns != graph.namespace {
+		return qualifiedName(ns, name)
+	}
+	return name
+}
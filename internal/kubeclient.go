@@ -2,24 +2,57 @@ package internal
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log"
-	"strings"
+	"sync"
+	"time"
 
 	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/discovery"
 	"k8s.io/client-go/dynamic"
 	"k8s.io/client-go/rest"
 	"k8s.io/client-go/tools/clientcmd"
 )
 
 type KubeClient struct {
-	openShift bool
-	dynClient dynamic.Interface
+	openShift      bool
+	dynClient      dynamic.Interface
+	discClient     discovery.DiscoveryInterface
+	cache          *resourceCache
+	discoveryOpts  DiscoveryOptions
+	discoveryMutex sync.RWMutex
+	versions       map[schema.GroupResource]string // preferred version of every served resource
+	failedGroups   map[string]struct{}             // groups that could not be discovered
+	discovered     []Extractor                     // generic extractors for discovered resources
+	fetchOpts      FetchOptions
+	missingNodes   bool // add placeholder nodes for dangling references
+	networkTraffic bool // link pods that network policies allow to talk to each other
+	stop           chan struct{}
 }
 
-func InitKubeClient(masterurl, kubeconfig string) (*KubeClient, error) {
+type KubeClientOptions struct {
+	Cache     CacheOptions
+	Discovery DiscoveryOptions
+	Fetch     FetchOptions
+
+	// MissingNodes adds placeholder nodes for referenced resources that do
+	// not exist.
+	MissingNodes bool
+
+	// NetworkTraffic links the pods whose traffic is allowed by network
+	// policies.
+	NetworkTraffic bool
+}
+
+type FetchOptions struct {
+	Workers int           // number of resources fetched concurrently
+	Timeout time.Duration // deadline for fetching all resources of a graph - 0 for no deadline
+}
+
+func InitKubeClient(masterurl, kubeconfig string, opts KubeClientOptions) (*KubeClient, error) {
 	var (
 		cfg *rest.Config
 		err error
@@ -44,553 +77,159 @@ func InitKubeClient(masterurl, kubeconfig string) (*KubeClient, error) {
 		return nil, fmt.Errorf("error getting new dynamic client: %v", err)
 	}
 
-	kc := KubeClient{dynClient: dynClient}
-	kc.openShift = kc.runningOnOpenShift(context.Background())
-
-	log.Printf("running on OpenShift: %v", kc.openShift)
-
-	return &kc, nil
-}
-
-func (kc *KubeClient) GetAll(ctx context.Context, namespace string) (Graph, error) {
-	graph := InitGraph()
-
-	if err := kc.GetConfigMaps(ctx, graph, namespace); err != nil {
-		log.Printf("error getting ConfigMaps: %v", err)
-	}
-
-	if err := kc.GetSecrets(ctx, graph, namespace); err != nil {
-		log.Printf("error getting Secrets: %v", err)
-	}
-
-	if err := kc.GetPersistentVolumeClaims(ctx, graph, namespace); err != nil {
-		log.Printf("error getting PersistentVolumeClaims: %v", err)
-	}
-
-	if err := kc.GetCronJobs(ctx, graph, namespace); err != nil {
-		log.Printf("error getting CronJobs: %v", err)
-	}
-
-	if err := kc.GetJobs(ctx, graph, namespace); err != nil {
-		log.Printf("error getting Jobs: %v", err)
-	}
-
-	if err := kc.GetDeploymentConfigs(ctx, graph, namespace); err != nil {
-		log.Printf("error getting DeploymentConfigs: %v", err)
-	}
-
-	if err := kc.GetBuildConfigs(ctx, graph, namespace); err != nil {
-		log.Printf("error getting BuildConfigs: %v", err)
-	}
-
-	if err := kc.GetBuilds(ctx, graph, namespace); err != nil {
-		log.Printf("error getting Builds: %v", err)
-	}
-
-	if err := kc.GetDeployments(ctx, graph, namespace); err != nil {
-		log.Printf("error getting Deployments: %v", err)
-	}
-
-	if err := kc.GetStatefulSets(ctx, graph, namespace); err != nil {
-		log.Printf("error getting StatefulSets: %v", err)
-	}
-
-	if err := kc.GetDaemonSets(ctx, graph, namespace); err != nil {
-		log.Printf("error getting DaemonSets: %v", err)
-	}
-
-	if err := kc.GetReplicaSets(ctx, graph, namespace); err != nil {
-		log.Printf("error getting ReplicaSets: %v", err)
-	}
-
-	if err := kc.GetReplicationControllers(ctx, graph, namespace); err != nil {
-		log.Printf("error getting ReplicationControllers: %v", err)
-	}
-
-	if err := kc.GetPods(ctx, graph, namespace); err != nil {
-		log.Printf("error getting Pods: %v", err)
-	}
-
-	if err := kc.GetServices(ctx, graph, namespace); err != nil {
-		log.Printf("error getting Services: %v", err)
-	}
-
-	if err := kc.GetRoutes(ctx, graph, namespace); err != nil {
-		log.Printf("error getting Routes: %v", err)
-	}
-
-	if err := kc.GetEndpointSlices(ctx, graph, namespace); err != nil {
-		log.Printf("error getting EndpointSlices: %v", err)
-	}
-
-	// this is needed because d3.js doesn't like links pointing to nodes that
-	// don't exist
-	graph.cleanLinks()
-
-	graph.cleanNodes()
-
-	return *graph, nil
-}
-
-func (kc *KubeClient) GetCronJobs(ctx context.Context, graph *Graph, namespace string) error {
-	items, err := kc.get(ctx, "batch", "v1beta1", "cronjobs", namespace)
+	discClient, err := discovery.NewDiscoveryClientForConfig(cfg)
 	if err != nil {
-		return err
+		return nil, fmt.Errorf("error getting new discovery client: %v", err)
 	}
 
-	for _, item := range items {
-		graph.addNode(string(item.GetUID()), "cj", item.GetName(), item.Object)
-		addOwnerLinks(item, graph)
+	kc := KubeClient{
+		dynClient:      dynClient,
+		discClient:     discClient,
+		cache:          newResourceCache(dynClient, opts.Cache),
+		discoveryOpts:  opts.Discovery,
+		fetchOpts:      opts.Fetch,
+		missingNodes:   opts.MissingNodes,
+		networkTraffic: opts.NetworkTraffic,
+		stop:           make(chan struct{}),
 	}
+	kc.openShift = kc.runningOnOpenShift(context.Background())
 
-	return nil
-}
+	log.Printf("running on OpenShift: %v", kc.openShift)
 
-func (kc *KubeClient) GetJobs(ctx context.Context, graph *Graph, namespace string) error {
-	items, err := kc.get(ctx, "batch", "v1", "jobs", namespace)
-	if err != nil {
-		return err
+	if err := kc.refreshDiscovery(); err != nil {
+		if opts.Discovery.Enabled {
+			return nil, err
+		}
+		// we can still fall back to the versions hard-coded in the
+		// extractors
+		log.Print(err)
 	}
-
-	for _, item := range items {
-		graph.addNode(string(item.GetUID()), "job", item.GetName(), item.Object)
-		addOwnerLinks(item, graph)
+	if opts.Discovery.Refresh > 0 {
+		go kc.refreshDiscoveryLoop()
 	}
 
-	return nil
+	return &kc, nil
 }
 
-func (kc *KubeClient) GetBuildConfigs(ctx context.Context, graph *Graph, namespace string) error {
-	if !kc.openShift {
-		return nil
-	}
-	items, err := kc.get(ctx, "build.openshift.io", "v1", "buildconfigs", namespace)
-	if err != nil {
-		return err
-	}
-
-	for _, item := range items {
-		graph.addNode(string(item.GetUID()), "buildconfig", item.GetName(), item.Object)
-		addOwnerLinks(item, graph)
-	}
-
-	return nil
-}
+func (kc *KubeClient) GetAll(ctx context.Context, namespace string) (Graph, error) {
+	graph := InitGraph()
+	graph.namespace = namespace
 
-func (kc *KubeClient) GetBuilds(ctx context.Context, graph *Graph, namespace string) error {
-	if !kc.openShift {
-		return nil
-	}
-	items, err := kc.get(ctx, "build.openshift.io", "v1", "builds", namespace)
+	extractors, err := registry.ordered()
 	if err != nil {
-		return err
+		return Graph{}, err
 	}
+	// discovered resources are only linked to their owners, so they do not
+	// depend on any other kind
+	extractors = append(extractors, kc.discoveredExtractors()...)
 
-	for _, item := range items {
-		graph.addNode(string(item.GetUID()), "build", item.GetName(), item.Object)
-		addOwnerLinks(item, graph)
+	if kc.fetchOpts.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, kc.fetchOpts.Timeout)
+		defer cancel()
+	}
+	results := kc.fetchAll(ctx, extractors, namespace)
 
-		imageDigest := unstructGetString(item.Object, "status", "output", "to", "imageDigest")
-		if imageDigest == "" {
+	// all nodes are added before any links are resolved, so that links can
+	// point to nodes of any kind
+	for i, e := range extractors {
+		switch {
+		case results[i].skipped:
 			continue
+		case results[i].err != nil:
+			// items cached before the error are still graphed, but we cannot
+			// tell whether references to the kind are dangling
+			log.Printf("error getting %s: %v", gvrString(results[i].gvr), results[i].err)
+			graph.addWarning(newWarning(e.Kind(), results[i].gvr, results[i].err))
+		default:
+			graph.markLoaded(e.Kind())
 		}
-		colon := strings.LastIndex(imageDigest, ":")
-		var uid string
-		if colon == -1 {
-			uid = imageDigest
-		} else {
-			uid = imageDigest[colon+1:]
+		for _, item := range results[i].items {
+			e.AddNodes(graph, item)
 		}
-		graph.addNode(uid, "image", imageDigest, nil)
-		graph.addLink(string(item.GetUID()), uid)
-	}
-
-	return nil
-}
-
-func (kc *KubeClient) GetDeploymentConfigs(ctx context.Context, graph *Graph, namespace string) error {
-	if !kc.openShift {
-		return nil
-	}
-	items, err := kc.get(ctx, "apps.openshift.io", "v1", "deploymentconfigs", namespace)
-	if err != nil {
-		return err
-	}
-
-	for _, item := range items {
-		graph.addNode(string(item.GetUID()), "dc", item.GetName(), item.Object)
-		addOwnerLinks(item, graph)
-	}
-
-	return nil
-}
-
-func (kc *KubeClient) GetDeployments(ctx context.Context, graph *Graph, namespace string) error {
-	items, err := kc.get(ctx, "apps", "v1", "deployments", namespace)
-	if err != nil {
-		return err
-	}
-
-	for _, item := range items {
-		graph.addNode(string(item.GetUID()), "deployment", item.GetName(), item.Object)
-		addOwnerLinks(item, graph)
 	}
-
-	return nil
-}
-
-func (kc *KubeClient) GetStatefulSets(ctx context.Context, graph *Graph, namespace string) error {
-	items, err := kc.get(ctx, "apps", "v1", "statefulsets", namespace)
-	if err != nil {
-		return err
-	}
-
-	for _, item := range items {
-		graph.addNode(string(item.GetUID()), "sts", item.GetName(), item.Object)
-		addOwnerLinks(item, graph)
-	}
-
-	return nil
-}
-
-func (kc *KubeClient) GetDaemonSets(ctx context.Context, graph *Graph, namespace string) error {
-	items, err := kc.get(ctx, "apps", "v1", "daemonsets", namespace)
-	if err != nil {
-		return err
+	for i, e := range extractors {
+		for _, item := range results[i].items {
+			e.AddLinks(graph, item)
+		}
 	}
-
-	for _, item := range items {
-		graph.addNode(string(item.GetUID()), "ds", item.GetName(), item.Object)
-		addOwnerLinks(item, graph)
+	graph.resolveReferences(kc.missingNodes)
+	if kc.networkTraffic {
+		graph.addTrafficLinks()
 	}
 
-	return nil
-}
+	// this is needed because d3.js doesn't like links pointing to nodes that
+	// don't exist
+	graph.cleanLinks()
 
-func (kc *KubeClient) GetReplicaSets(ctx context.Context, graph *Graph, namespace string) error {
-	items, err := kc.get(ctx, "apps", "v1", "replicasets", namespace)
-	if err != nil {
-		return err
-	}
+	graph.cleanNodes()
 
-	for _, item := range items {
-		graph.addNode(string(item.GetUID()), "replicaset", item.GetName(), item.Object)
-		addOwnerLinks(item, graph)
-	}
+	// links between cluster-scoped nodes that were cleaned out
+	graph.cleanLinks()
 
-	return nil
+	return *graph, nil
 }
 
-func (kc *KubeClient) GetReplicationControllers(ctx context.Context, graph *Graph, namespace string) error {
-	items, err := kc.get(ctx, "", "v1", "replicationcontrollers", namespace)
-	if err != nil {
-		return err
-	}
-
-	for _, item := range items {
-		graph.addNode(string(item.GetUID()), "rc", item.GetName(), item.Object)
-		addOwnerLinks(item, graph)
-	}
-
-	return nil
+type fetchResult struct {
+	gvr     schema.GroupVersionResource
+	items   []unstructured.Unstructured // may be set along with err if stale items are cached
+	err     error
+	skipped bool // the resource does not apply to this cluster
 }
 
-func (kc *KubeClient) GetPods(ctx context.Context, graph *Graph, namespace string) error {
-	items, err := kc.get(ctx, "", "v1", "pods", namespace)
-	if err != nil {
-		return err
-	}
-
-	for _, item := range items {
-		podid := string(item.GetUID())
-
-		graph.addNode(podid, "pod", item.GetName(), item.Object)
-		addOwnerLinks(item, graph)
-
-		// check if we need to link to container images
-		containers := unstructGetList(item.Object, "spec", "containers")
-		if len(containers) > 0 {
-			for _, c := range containers {
-				cm, ok := c.(map[string]interface{})
-				if !ok {
-					continue
-				}
-				image := unstructGetString(cm, "image")
-				if image == "" {
-					continue
-				}
-				sep := strings.LastIndex(image, "@sha256:")
-				if sep == -1 {
-					continue
-				}
-				graph.addLink(podid, image[sep+len("@sha256:"):])
-
-				// check for .spec.containers[*].envFrom
-				ef := unstructGetList(cm, "envFrom")
-				if len(ef) > 0 {
-					for _, efitem := range ef {
-						efitemmap, ok := efitem.(map[string]interface{})
-						if !ok {
-							continue
-						}
-
-						// check for .spec.containers[*].envFrom[*].configMapRef.name
-						cmName := unstructGetString(efitemmap, "configMapRef", "name")
-						if cmName != "" {
-							uid := graph.findResource("cm", cmName)
-							if uid == "" {
-								continue
-							}
-							graph.addLink(podid, uid)
-						} else {
-							// check for .spec.containers[*].envFrom[*].secretRef.name
-							secretName := unstructGetString(efitemmap, "secretRef", "name")
-							if secretName != "" {
-								uid := graph.findResource("secret", secretName)
-								if uid == "" {
-									continue
-								}
-								graph.addLink(podid, uid)
-							}
-						}
-					}
-				}
-
-				// check for .spec.containers[*].env
-				env := unstructGetList(cm, "env")
-				if len(env) > 0 {
-					for _, envItem := range env {
-						envMap, ok := envItem.(map[string]interface{})
-						if !ok {
-							continue
-						}
-
-						// check for .spec.containers[*].env[*].valueFrom
-						vf := unstructGetMap(envMap, "valueFrom")
-						if vf == nil {
-							continue
-						}
-
-						// check for .spec.containers[*].env[*].valueFrom.configMapKeyRef.name
-						cmName := unstructGetString(vf, "configMapKeyRef", "name")
-						if cmName != "" {
-							uid := graph.findResource("cm", cmName)
-							if uid == "" {
-								continue
-							}
-							graph.addLink(podid, uid)
-						} else {
-							// check for .spec.containers[*].env[*].valueFrom.secretKeyRef.name
-							secretName := unstructGetString(vf, "secretKeyRef", "name")
-							if secretName != "" {
-								uid := graph.findResource("secret", secretName)
-								if uid == "" {
-									continue
-								}
-								graph.addLink(podid, uid)
-							}
-						}
-					}
-				}
+// fetchAll lists the resources of all extractors concurrently. The results
+// are in the same order as the extractors.
+func (kc *KubeClient) fetchAll(ctx context.Context, extractors []Extractor, namespace string) []fetchResult {
+	results := make([]fetchResult, len(extractors))
+
+	workers := kc.fetchOpts.Workers
+	if workers < 1 {
+		workers = 1
+	}
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				results[i] = kc.fetch(ctx, extractors[i], namespace)
 			}
-		}
-
-		volumes := unstructGetList(item.Object, "spec", "volumes")
-		if len(volumes) > 0 {
-			for _, v := range volumes {
-				volume, ok := v.(map[string]interface{})
-				if !ok {
-					continue
-				}
-				claimName := unstructGetString(volume, "persistentVolumeClaim", "claimName")
-				if claimName != "" {
-					claimUid := graph.findResource("pvc", claimName)
-					if claimUid == "" {
-						continue
-					}
-					graph.addLink(podid, claimUid)
-					continue
-				}
-				cmName := unstructGetString(volume, "configMap", "name")
-				if cmName != "" {
-					cmUid := graph.findResource("cm", cmName)
-					if cmUid == "" {
-						continue
-					}
-					graph.addLink(podid, cmUid)
-					continue
-				}
-				secretName := unstructGetString(volume, "secret", "secretName")
-				if secretName != "" {
-					secretUid := graph.findResource("secret", secretName)
-					if secretUid == "" {
-						continue
-					}
-					graph.addLink(podid, secretUid)
-					continue
-				}
-
-			}
-		}
-	}
-
-	return nil
-}
-
-func addOwnerLinks(u unstructured.Unstructured, graph *Graph) {
-	for _, owner := range unstructGetOwners(u) {
-		graph.addLink(owner, string(u.GetUID()))
-	}
-}
-
-func (kc *KubeClient) GetPersistentVolumeClaims(ctx context.Context, graph *Graph, namespace string) error {
-	items, err := kc.get(ctx, "", "v1", "persistentvolumeclaims", namespace)
-	if err != nil {
-		return err
+		}()
 	}
 
-	for _, item := range items {
-		graph.addNode(string(item.GetUID()), "pvc", item.GetName(), item.Object)
+	for i := range extractors {
+		jobs <- i
 	}
+	close(jobs)
+	wg.Wait()
 
-	return nil
+	return results
 }
 
-func (kc *KubeClient) GetConfigMaps(ctx context.Context, graph *Graph, namespace string) error {
-	items, err := kc.get(ctx, "", "v1", "configmaps", namespace)
-	if err != nil {
-		return err
-	}
-
-	for _, item := range items {
-		graph.addNode(string(item.GetUID()), "cm", item.GetName(), item.Object)
+func (kc *KubeClient) fetch(ctx context.Context, e Extractor, namespace string) fetchResult {
+	result := fetchResult{gvr: e.GVR()}
+	if e.OpenShiftOnly() && !kc.openShift {
+		result.skipped = true
+		return result
 	}
 
-	return nil
-}
-
-func (kc *KubeClient) GetSecrets(ctx context.Context, graph *Graph, namespace string) error {
-	items, err := kc.get(ctx, "", "v1", "secrets", namespace)
+	gvr, err := kc.resolveGVR(e.GVR())
 	if err != nil {
-		return err
-	}
-
-	for _, item := range items {
-		graph.addNode(string(item.GetUID()), "secret", item.GetName(), item.Object)
-	}
-
-	return nil
-}
-
-func (kc *KubeClient) GetServices(ctx context.Context, graph *Graph, namespace string) error {
-	items, err := kc.get(ctx, "", "v1", "services", namespace)
-	if err != nil {
-		return err
-	}
-
-	for _, item := range items {
-		graph.addNode(string(item.GetUID()), "svc", item.GetName(), item.Object)
-		addOwnerLinks(item, graph)
-	}
-
-	return nil
-}
-
-func (kc *KubeClient) GetRoutes(ctx context.Context, graph *Graph, namespace string) error {
-	if !kc.openShift {
-		return nil
-	}
-	items, err := kc.get(ctx, "route.openshift.io", "v1", "routes", namespace)
-	if err != nil {
-		return err
-	}
-
-	for _, item := range items {
-		uid := string(item.GetUID())
-		graph.addNode(uid, "route", item.GetName(), item.Object)
-		addOwnerLinks(item, graph)
-
-		to := unstructGetMap(item.Object, "spec", "to")
-		if to != nil {
-			kind := unstructGetString(to, "kind")
-			if kind == "Service" {
-				name := unstructGetString(to, "name")
-				if name != "" {
-					svcuid := graph.findResource("svc", name)
-					if svcuid != "" {
-						graph.addLink(uid, svcuid)
-					}
-				}
-			}
-		}
-
-		altBackends := unstructGetList(item.Object, "spec", "alternateBackends")
-		if len(altBackends) > 0 {
-			for _, b := range altBackends {
-				backend, ok := b.(map[string]interface{})
-				if !ok {
-					continue
-				}
-				kind := unstructGetString(backend, "kind")
-				if kind != "Service" {
-					continue
-				}
-				name := unstructGetString(backend, "name")
-				if name == "" {
-					continue
-				}
-				svcuid := graph.findResource("svc", name)
-				if svcuid == "" {
-					continue
-				}
-				graph.addLink(uid, svcuid)
-			}
+		var notServed notServedError
+		if e.Optional() && errors.As(err, &notServed) {
+			result.skipped = true
+			return result
 		}
+		result.err = err
+		return result
 	}
-
-	return nil
-}
-
-func (kc *KubeClient) GetEndpointSlices(ctx context.Context, graph *Graph, namespace string) error {
-	items, err := kc.get(ctx, "discovery.k8s.io", "v1beta1", "endpointslices", namespace)
-	if err != nil {
-		return err
+	result.gvr = gvr
+	if e.ClusterScoped() || e.AllNamespaces() {
+		namespace = ""
 	}
-
-	for _, item := range items {
-		esuid := string(item.GetUID())
-		graph.addNode(esuid, "endpointslice", item.GetName(), item.Object)
-		addOwnerLinks(item, graph)
-
-		endpoints := unstructGetList(item.Object, "endpoints")
-		if len(endpoints) > 0 {
-			for _, e := range endpoints {
-				endpoint, ok := e.(map[string]interface{})
-				if !ok {
-					continue
-				}
-				targetRef := unstructGetMap(endpoint, "targetRef")
-				if targetRef == nil {
-					continue
-				}
-				kind := unstructGetString(targetRef, "kind")
-				if kind != "Pod" {
-					continue
-				}
-				podName := unstructGetString(targetRef, "name")
-				if podName == "" {
-					continue
-				}
-				poduid := graph.findResource("pod", podName)
-				if poduid == "" {
-					continue
-				}
-				graph.addLink(esuid, poduid)
-			}
-		}
-	}
-
-	return nil
+	result.items, result.err = kc.list(ctx, gvr.Group, gvr.Version, gvr.Resource, namespace)
+	return result
 }
 
 func (kc *KubeClient) GetProjects(ctx context.Context) ([]Project, error) {
@@ -655,6 +294,32 @@ func (kc *KubeClient) runningOnOpenShift(ctx context.Context) bool {
 	return err == nil
 }
 
+// GetCacheStatus returns the warm-up status of the informer cache.
+func (kc *KubeClient) GetCacheStatus() []CacheStatus {
+	return kc.cache.status()
+}
+
+// Close stops all informers and background refreshes.
+func (kc *KubeClient) Close() {
+	close(kc.stop)
+	kc.cache.close()
+}
+
+// list returns the resources in a namespace from the informer cache. An
+// empty namespace lists cluster-scoped resources or namespaced resources in
+// all namespaces. Cached resources may be returned along with an error if
+// the cache could not be refreshed.
+func (kc *KubeClient) list(ctx context.Context, g, v, r, namespace string) ([]unstructured.Unstructured, error) {
+	resource := schema.GroupVersionResource{Group: g, Version: v, Resource: r}
+
+	cached, err := kc.cache.list(ctx, resource, namespace)
+	items := make([]unstructured.Unstructured, 0, len(cached))
+	for _, item := range cached {
+		items = append(items, *item)
+	}
+	return items, err
+}
+
 func (kc *KubeClient) get(ctx context.Context, g, v, r, namespace string) ([]unstructured.Unstructured, error) {
 	resource := schema.GroupVersionResource{Group: g, Version: v, Resource: r}
 
@@ -0,0 +1,366 @@
+package internal
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/labels"
+)
+
+// NetworkPolicies are linked to the pods they select.
+func netpolLinks(graph *Graph, item unstructured.Unstructured) {
+	selector, err := unstructLabelSelector(unstructGetMap(item.Object, "spec", "podSelector"))
+	if err != nil {
+		return
+	}
+
+	uid := string(item.GetUID())
+	for _, poduid := range graph.FindBySelector("pod", selector) {
+		graph.AddLink(uid, poduid, LinkSelects, "")
+	}
+}
+
+// netpolSummary describes the directions a NetworkPolicy isolates and the
+// peers outside the namespace that it allows, e.g. "isolates ingress, egress;
+// ingress from namespaces team=a, 10.0.0.0/8".
+func netpolSummary(item unstructured.Unstructured) string {
+	ingress, egress := netpolTypes(item.Object)
+	types := []string{}
+	if ingress {
+		types = append(types, "ingress")
+	}
+	if egress {
+		types = append(types, "egress")
+	}
+	parts := []string{"isolates " + strings.Join(types, ", ")}
+
+	for _, direction := range []struct{ rules, peers string }{{"ingress", "from"}, {"egress", "to"}} {
+		external := []string{}
+		for _, r := range unstructGetList(item.Object, "spec", direction.rules) {
+			rule, ok := r.(map[string]interface{})
+			if !ok {
+				continue
+			}
+			for _, p := range unstructGetList(rule, direction.peers) {
+				peer, ok := p.(map[string]interface{})
+				if !ok {
+					continue
+				}
+				if cidr := unstructGetString(peer, "ipBlock", "cidr"); cidr != "" {
+					external = append(external, cidr)
+				}
+				if nsSelector, ok := peer["namespaceSelector"].(map[string]interface{}); ok {
+					selector, err := unstructLabelSelector(nsSelector)
+					if err != nil {
+						continue
+					}
+					if selector.Empty() {
+						external = append(external, "all namespaces")
+					} else {
+						external = append(external, "namespaces "+selector.String())
+					}
+				}
+			}
+		}
+		if len(external) > 0 {
+			parts = append(parts, fmt.Sprintf("%s %s %s", direction.rules, direction.peers, strings.Join(external, ", ")))
+		}
+	}
+
+	return strings.Join(parts, "; ")
+}
+
+// netpolTypes returns whether a NetworkPolicy isolates the ingress and egress
+// traffic of the pods it selects. Policies without policyTypes always isolate
+// ingress and isolate egress if they have egress rules.
+func netpolTypes(policy map[string]interface{}) (ingress, egress bool) {
+	policyTypes := unstructGetList(policy, "spec", "policyTypes")
+	if len(policyTypes) == 0 {
+		_, hasEgress := unstructGetMap(policy, "spec")["egress"]
+		return true, hasEgress
+	}
+	for _, t := range policyTypes {
+		switch t {
+		case "Ingress":
+			ingress = true
+		case "Egress":
+			egress = true
+		}
+	}
+	return ingress, egress
+}
+
+// addTrafficLinks links every pair of pods in the namespace that may talk to
+// each other under the namespace's NetworkPolicies. Only pairs where the
+// target is isolated for ingress or the source is isolated for egress are
+// linked, as all other traffic is allowed anyway. The detail lists the
+// ports allowed by both the egress and the ingress policies if the traffic
+// is restricted to some ports, and pairs without such ports are not linked.
+//
+// Namespace selectors are matched against the kubernetes.io/metadata.name
+// label only, as the labels of the namespace are not known, and ipBlock
+// peers are ignored.
+func (g *Graph) addTrafficLinks() {
+	policies := []*Node{}
+	pods := []*Node{}
+	for _, node := range g.Nodes {
+		switch {
+		case node.Status == NodeStatusMissing:
+		case node.Kind == "netpol":
+			policies = append(policies, node)
+		case node.Kind == "pod":
+			phase := unstructGetString(node.Object, "status", "phase")
+			if phase != "Succeeded" && phase != "Failed" {
+				pods = append(pods, node)
+			}
+		}
+	}
+	if len(policies) == 0 {
+		return
+	}
+
+	ingressPolicies := make(map[string][]*Node) // pod uid to policies isolating its ingress
+	egressPolicies := make(map[string][]*Node)  // pod uid to policies isolating its egress
+	for _, policy := range policies {
+		selector, err := unstructLabelSelector(unstructGetMap(policy.Object, "spec", "podSelector"))
+		if err != nil {
+			continue
+		}
+		ingress, egress := netpolTypes(policy.Object)
+		for _, pod := range pods {
+			if !selector.Matches(unstructGetLabels(pod.Object)) {
+				continue
+			}
+			if ingress {
+				ingressPolicies[pod.Uid] = append(ingressPolicies[pod.Uid], policy)
+			}
+			if egress {
+				egressPolicies[pod.Uid] = append(egressPolicies[pod.Uid], policy)
+			}
+		}
+	}
+
+	nsLabels := labels.Set{"kubernetes.io/metadata.name": g.namespace}
+	for _, source := range pods {
+		for _, target := range pods {
+			if source.Uid == target.Uid {
+				continue
+			}
+			ingress, isolatedIngress := ingressPolicies[target.Uid]
+			egress, isolatedEgress := egressPolicies[source.Uid]
+			if !isolatedIngress && !isolatedEgress {
+				continue
+			}
+
+			// nil port ranges allow all ports
+			var ports []portRange
+			if isolatedEgress {
+				allowed, egressPorts := netpolAllows(egress, "egress", "to", target, target, nsLabels)
+				if !allowed {
+					continue
+				}
+				ports = egressPorts
+			}
+			if isolatedIngress {
+				allowed, ingressPorts := netpolAllows(ingress, "ingress", "from", source, target, nsLabels)
+				if !allowed {
+					continue
+				}
+				ports = intersectPorts(ports, ingressPorts)
+			}
+			if ports != nil && len(ports) == 0 {
+				// egress and ingress are allowed on different ports
+				continue
+			}
+			g.AddLink(source.Uid, target.Uid, LinkTraffic, formatPorts(ports))
+		}
+	}
+}
+
+// portRange is a range of ports of a protocol allowed by a network policy.
+type portRange struct {
+	protocol string
+	from, to int
+}
+
+// netpolAllows returns whether a rule of any of the policies allows traffic
+// from or to a peer pod, and the ports of the target pod the traffic is
+// allowed on - nil for all ports.
+func netpolAllows(policies []*Node, rulesField, peersField string, peer, target *Node, nsLabels labels.Set) (bool, []portRange) {
+	allowed := false
+	ports := []portRange{}
+	for _, policy := range policies {
+		for _, r := range unstructGetList(policy.Object, "spec", rulesField) {
+			rule, ok := r.(map[string]interface{})
+			if !ok {
+				continue
+			}
+			if !netpolRuleMatches(rule, peersField, peer, nsLabels) {
+				continue
+			}
+			rulePorts := unstructGetList(rule, "ports")
+			if len(rulePorts) == 0 {
+				return true, nil
+			}
+			for _, p := range rulePorts {
+				port, ok := p.(map[string]interface{})
+				if !ok {
+					continue
+				}
+				if r, ok := netpolPort(port, target); ok {
+					allowed = true
+					ports = append(ports, r)
+				}
+			}
+		}
+	}
+	return allowed, ports
+}
+
+// rules without peers match all peers
+func netpolRuleMatches(rule map[string]interface{}, peersField string, pod *Node, nsLabels labels.Set) bool {
+	peers, ok := rule[peersField].([]interface{})
+	if !ok || len(peers) == 0 {
+		return true
+	}
+	for _, p := range peers {
+		peer, ok := p.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		podSelectorMap, hasPodSelector := peer["podSelector"].(map[string]interface{})
+		nsSelectorMap, hasNsSelector := peer["namespaceSelector"].(map[string]interface{})
+		if !hasPodSelector && !hasNsSelector {
+			// ipBlock
+			continue
+		}
+		if hasNsSelector {
+			selector, err := unstructLabelSelector(nsSelectorMap)
+			if err != nil || !selector.Matches(nsLabels) {
+				continue
+			}
+		}
+		if hasPodSelector {
+			selector, err := unstructLabelSelector(podSelectorMap)
+			if err != nil || !selector.Matches(unstructGetLabels(pod.Object)) {
+				continue
+			}
+		}
+		return true
+	}
+	return false
+}
+
+// netpolPort converts a NetworkPolicyPort to a port range. Named ports are
+// resolved with the container ports of the target pod - ok is false if the
+// target pod has no port of that name.
+func netpolPort(port map[string]interface{}, target *Node) (r portRange, ok bool) {
+	r.protocol = valueOr(unstructGetString(port, "protocol"), "TCP")
+	switch number := port["port"].(type) {
+	case nil:
+		r.from, r.to = 1, 65535
+		return r, true
+	case int64:
+		r.from = int(number)
+	case float64:
+		r.from = int(number)
+	case string:
+		if r.from = namedContainerPort(target, number, r.protocol); r.from == 0 {
+			return r, false
+		}
+	default:
+		return r, false
+	}
+	r.to = r.from
+	switch endPort := port["endPort"].(type) {
+	case int64:
+		r.to = int(endPort)
+	case float64:
+		r.to = int(endPort)
+	}
+	return r, true
+}
+
+// namedContainerPort returns the number of a named container port of a pod,
+// or 0 if there is no such port.
+func namedContainerPort(pod *Node, name, protocol string) int {
+	for _, c := range unstructGetList(pod.Object, "spec", "containers") {
+		container, ok := c.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		for _, p := range unstructGetList(container, "ports") {
+			port, ok := p.(map[string]interface{})
+			if !ok || unstructGetString(port, "name") != name {
+				continue
+			}
+			if valueOr(unstructGetString(port, "protocol"), "TCP") != protocol {
+				continue
+			}
+			switch number := port["containerPort"].(type) {
+			case int64:
+				return int(number)
+			case float64:
+				return int(number)
+			}
+		}
+	}
+	return 0
+}
+
+// intersectPorts returns the port ranges allowed by both a and b, where nil
+// allows all ports.
+func intersectPorts(a, b []portRange) []portRange {
+	if a == nil {
+		return b
+	}
+	if b == nil {
+		return a
+	}
+	both := []portRange{}
+	for _, ra := range a {
+		for _, rb := range b {
+			if ra.protocol != rb.protocol {
+				continue
+			}
+			r := portRange{protocol: ra.protocol, from: ra.from, to: ra.to}
+			if rb.from > r.from {
+				r.from = rb.from
+			}
+			if rb.to < r.to {
+				r.to = rb.to
+			}
+			if r.from <= r.to {
+				both = append(both, r)
+			}
+		}
+	}
+	return both
+}
+
+// formatPorts formats port ranges as a link detail, e.g. "TCP/80, UDP/5000-5010"
+// - "" for all ports.
+func formatPorts(ports []portRange) string {
+	seen := make(map[string]struct{})
+	list := []string{}
+	for _, r := range ports {
+		var s string
+		switch {
+		case r.from == 1 && r.to == 65535:
+			s = r.protocol
+		case r.from == r.to:
+			s = fmt.Sprintf("%s/%d", r.protocol, r.from)
+		default:
+			s = fmt.Sprintf("%s/%d-%d", r.protocol, r.from, r.to)
+		}
+		if _, ok := seen[s]; ok {
+			continue
+		}
+		seen[s] = struct{}{}
+		list = append(list, s)
+	}
+	sort.Strings(list)
+	return strings.Join(list, ", ")
+}
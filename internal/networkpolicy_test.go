@@ -0,0 +1,183 @@
+package internal
+
+import (
+	"reflect"
+	"testing"
+)
+
+func testPod(name, app string, ports ...map[string]interface{}) map[string]interface{} {
+	containerPorts := []interface{}{}
+	for _, p := range ports {
+		containerPorts = append(containerPorts, p)
+	}
+	return map[string]interface{}{
+		"metadata": map[string]interface{}{
+			"name":   name,
+			"labels": map[string]interface{}{"app": app},
+		},
+		"spec": map[string]interface{}{
+			"containers": []interface{}{
+				map[string]interface{}{"name": name, "ports": containerPorts},
+			},
+		},
+		"status": map[string]interface{}{"phase": "Running"},
+	}
+}
+
+// testPolicy returns a NetworkPolicy selecting the pods of an app with a
+// single rule allowing traffic from or to the pods of a peer app.
+func testPolicy(app, rules, peers, peerApp string, ports ...map[string]interface{}) map[string]interface{} {
+	rule := map[string]interface{}{
+		peers: []interface{}{
+			map[string]interface{}{
+				"podSelector": map[string]interface{}{
+					"matchLabels": map[string]interface{}{"app": peerApp},
+				},
+			},
+		},
+	}
+	if len(ports) > 0 {
+		rulePorts := []interface{}{}
+		for _, p := range ports {
+			rulePorts = append(rulePorts, p)
+		}
+		rule["ports"] = rulePorts
+	}
+	policyType := "Ingress"
+	if rules == "egress" {
+		policyType = "Egress"
+	}
+	return map[string]interface{}{
+		"spec": map[string]interface{}{
+			"podSelector": map[string]interface{}{
+				"matchLabels": map[string]interface{}{"app": app},
+			},
+			"policyTypes": []interface{}{policyType},
+			rules:         []interface{}{rule},
+		},
+	}
+}
+
+func TestAddTrafficLinksPorts(t *testing.T) {
+	tcp := func(port interface{}) map[string]interface{} {
+		return map[string]interface{}{"protocol": "TCP", "port": port}
+	}
+	tcpRange := func(from, to int64) map[string]interface{} {
+		return map[string]interface{}{"protocol": "TCP", "port": from, "endPort": to}
+	}
+
+	tests := []struct {
+		name     string
+		policies []map[string]interface{}
+		links    map[string]string // source->target to detail
+	}{
+		{
+			name: "ingress without ports",
+			policies: []map[string]interface{}{
+				testPolicy("db", "ingress", "from", "web"),
+			},
+			links: map[string]string{"web->db": ""},
+		},
+		{
+			name: "ingress port",
+			policies: []map[string]interface{}{
+				testPolicy("db", "ingress", "from", "web", tcp(int64(5432))),
+			},
+			links: map[string]string{"web->db": "TCP/5432"},
+		},
+		{
+			name: "named port",
+			policies: []map[string]interface{}{
+				testPolicy("db", "ingress", "from", "web", tcp("sql")),
+			},
+			links: map[string]string{"web->db": "TCP/5432"},
+		},
+		{
+			name: "unknown named port",
+			policies: []map[string]interface{}{
+				testPolicy("db", "ingress", "from", "web", tcp("http")),
+			},
+			links: map[string]string{},
+		},
+		{
+			name: "protocol without port",
+			policies: []map[string]interface{}{
+				testPolicy("db", "ingress", "from", "web", map[string]interface{}{"protocol": "UDP"}),
+			},
+			links: map[string]string{"web->db": "UDP"},
+		},
+		{
+			name: "egress and ingress ports intersect",
+			policies: []map[string]interface{}{
+				testPolicy("web", "egress", "to", "db", tcpRange(5000, 6000)),
+				testPolicy("db", "ingress", "from", "web", tcp(int64(5432)), tcp(int64(8080))),
+			},
+			links: map[string]string{"web->db": "TCP/5432"},
+		},
+		{
+			name: "egress and ingress ranges overlap",
+			policies: []map[string]interface{}{
+				testPolicy("web", "egress", "to", "db", tcpRange(5000, 6000)),
+				testPolicy("db", "ingress", "from", "web", tcpRange(5500, 7000)),
+			},
+			links: map[string]string{"web->db": "TCP/5500-6000"},
+		},
+		{
+			name: "egress and ingress ports disjoint",
+			policies: []map[string]interface{}{
+				testPolicy("web", "egress", "to", "db", tcp(int64(443))),
+				testPolicy("db", "ingress", "from", "web", tcp(int64(5432))),
+			},
+			links: map[string]string{},
+		},
+		{
+			name: "egress ports and ingress without ports",
+			policies: []map[string]interface{}{
+				testPolicy("web", "egress", "to", "db", tcp(int64(5432)), tcp(int64(5432))),
+				testPolicy("db", "ingress", "from", "web"),
+			},
+			links: map[string]string{"web->db": "TCP/5432"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			graph := InitGraph()
+			graph.namespace = "test"
+			graph.AddNode("web", "pod", "web", testPod("web", "web"))
+			graph.AddNode("db", "pod", "db", testPod("db", "db", map[string]interface{}{"name": "sql", "containerPort": int64(5432)}))
+			for i, policy := range test.policies {
+				graph.AddNode(string(rune('a'+i)), "netpol", "policy", policy)
+			}
+
+			graph.addTrafficLinks()
+
+			links := make(map[string]string)
+			for _, l := range graph.Links {
+				if l.Type == LinkTraffic {
+					links[l.Source+"->"+l.Target] = l.Detail
+				}
+			}
+			if !reflect.DeepEqual(links, test.links) {
+				t.Errorf("got traffic links %v - expected %v", links, test.links)
+			}
+		})
+	}
+}
+
+func TestFormatPorts(t *testing.T) {
+	ports := []portRange{
+		{protocol: "UDP", from: 53, to: 53},
+		{protocol: "TCP", from: 8000, to: 8080},
+		{protocol: "TCP", from: 80, to: 80},
+		{protocol: "TCP", from: 80, to: 80},
+		{protocol: "SCTP", from: 1, to: 65535},
+	}
+	expected := "SCTP, TCP/80, TCP/8000-8080, UDP/53"
+	if detail := formatPorts(ports); detail != expected {
+		t.Errorf("formatPorts() = %q - expected %q", detail, expected)
+	}
+	if detail := formatPorts(nil); detail != "" {
+		t.Errorf("formatPorts(nil) = %q - expected \"\"", detail)
+	}
+}
@@ -0,0 +1,165 @@
+package internal
+
+import "context"
+
+// kinds of problems
+const (
+	ProblemDanglingReference  = "dangling-reference" // reference to a resource that does not exist
+	ProblemNoDisruptionBudget = "no-pdb"             // workload pods not covered by a PodDisruptionBudget
+	ProblemMultipleBudgets    = "multiple-pdbs"      // workload pods covered by more than one PodDisruptionBudget
+)
+
+// Problem is a reference from a resource to another resource that does not
+// exist, or a workload whose pods are not protected by exactly one
+// PodDisruptionBudget.
+type Problem struct {
+	Problem    string `json:"problem"`
+	SourceId   string `json:"sourceid"`
+	SourceKind string `json:"sourcekind"`
+	SourceName string `json:"sourcename"`
+	TargetKind string `json:"targetkind,omitempty"`
+	TargetName string `json:"targetname,omitempty"`
+	Type       string `json:"type,omitempty"`
+	Detail     string `json:"detail,omitempty"`
+}
+
+// GetProblems returns all dangling references and disruption budget coverage
+// problems in a namespace.
+func (kc *KubeClient) GetProblems(ctx context.Context, namespace string) ([]Problem, error) {
+	graph, err := kc.GetAll(ctx, namespace)
+	if err != nil {
+		return nil, err
+	}
+	return graph.problems(), nil
+}
+
+func (g *Graph) problems() []Problem {
+	all := []Problem{}
+
+	for _, ref := range g.Unresolved {
+		source, ok := g.nodeMap[ref.Source]
+		if !ok {
+			// the source was cleaned out of the graph
+			continue
+		}
+		all = append(all, Problem{
+			Problem:    ProblemDanglingReference,
+			SourceId:   ref.Source,
+			SourceKind: source.Kind,
+			SourceName: source.Name,
+			TargetKind: ref.TargetKind,
+			TargetName: ref.TargetName,
+			Type:       ref.Type,
+			Detail:     ref.Detail,
+		})
+	}
+
+	return append(all, g.disruptionBudgetProblems()...)
+}
+
+// disruptionBudgetProblems reports the workloads with pods that are selected
+// by no PodDisruptionBudget, and the PodDisruptionBudgets that overlap on a
+// workload's pods. Pods of jobs and pods that have terminated are ignored as
+// they are not affected by evictions, and pods of daemon sets are ignored as
+// draining a node does not evict them.
+func (g *Graph) disruptionBudgetProblems() []Problem {
+	problems := []Problem{}
+	if _, ok := g.loadedKinds["pdb"]; !ok {
+		// we cannot tell which pods are covered
+		return problems
+	}
+
+	owners := make(map[string]string)    // uid to owner uid
+	budgets := make(map[string][]string) // pod uid to pdb uids
+	for _, link := range g.Links {
+		switch link.Type {
+		case LinkOwns:
+			owners[link.Target] = link.Source
+		case LinkSelects:
+			if source, ok := g.nodeMap[link.Source]; ok && source.Kind == "pdb" {
+				budgets[link.Target] = append(budgets[link.Target], link.Source)
+			}
+		}
+	}
+
+	uncovered := make(map[string]struct{})              // workload uids
+	overlapping := make(map[string]map[string]struct{}) // workload uid to pdb uids
+	workloads := []string{}                             // in graph order
+	seen := make(map[string]struct{})
+	for _, node := range g.Nodes {
+		if node.Kind != "pod" || node.Status == NodeStatusMissing {
+			continue
+		}
+		phase := unstructGetString(node.Object, "status", "phase")
+		if phase == "Succeeded" || phase == "Failed" {
+			continue
+		}
+		workload := rootOwner(owners, node.Uid)
+		if kind := g.nodeMap[workload].Kind; kind == "job" || kind == "cj" || kind == "ds" {
+			continue
+		}
+
+		if _, ok := seen[workload]; !ok {
+			seen[workload] = struct{}{}
+			workloads = append(workloads, workload)
+		}
+		pdbs := budgets[node.Uid]
+		switch {
+		case len(pdbs) == 0:
+			uncovered[workload] = struct{}{}
+		case len(pdbs) > 1:
+			if overlapping[workload] == nil {
+				overlapping[workload] = make(map[string]struct{})
+			}
+			for _, pdb := range pdbs {
+				overlapping[workload][pdb] = struct{}{}
+			}
+		}
+	}
+
+	for _, workload := range workloads {
+		node := g.nodeMap[workload]
+		if _, ok := uncovered[workload]; ok {
+			problems = append(problems, Problem{
+				Problem:    ProblemNoDisruptionBudget,
+				SourceId:   workload,
+				SourceKind: node.Kind,
+				SourceName: node.Name,
+			})
+		}
+		pdbs := overlapping[workload]
+		for _, n := range g.Nodes {
+			if _, ok := pdbs[n.Uid]; !ok {
+				continue
+			}
+			problems = append(problems, Problem{
+				Problem:    ProblemMultipleBudgets,
+				SourceId:   workload,
+				SourceKind: node.Kind,
+				SourceName: node.Name,
+				TargetKind: n.Kind,
+				TargetName: n.Name,
+				Type:       LinkSelects,
+			})
+		}
+	}
+
+	return problems
+}
+
+// rootOwner follows owner links up to the top-level controller of a node,
+// e.g. from a pod to its deployment. Nodes without owners are their own root.
+func rootOwner(owners map[string]string, uid string) string {
+	seen := make(map[string]struct{})
+	for {
+		seen[uid] = struct{}{}
+		owner, ok := owners[uid]
+		if !ok {
+			return uid
+		}
+		if _, loop := seen[owner]; loop {
+			return uid
+		}
+		uid = owner
+	}
+}
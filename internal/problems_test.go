@@ -0,0 +1,66 @@
+package internal
+
+import (
+	"reflect"
+	"sort"
+	"testing"
+)
+
+// References from external nodes to resources that do not exist must neither
+// be reported as problems of the namespace nor pull the external nodes into
+// the graph through placeholders.
+func TestDanglingReferencesFromExternalNodes(t *testing.T) {
+	graph := InitGraph()
+	graph.namespace = "team-a"
+	for _, kind := range []string{"pod", "cm", "pv", "storageclass", "crb", "clusterrole"} {
+		graph.markLoaded(kind)
+	}
+
+	graph.AddNode("pod", "pod", "app", nil)
+	graph.AddNode("pv", "pv", "static-pv", nil)
+	graph.markExternal("pv", "")
+	graph.AddNode("crb", "crb", "admins", nil)
+	graph.markExternal("crb", "")
+
+	graph.AddReference("pod", "cm", "config", LinkMounts, "")
+	graph.AddReference("pv", "storageclass", "manual", LinkClass, "")
+	graph.AddReference("crb", "clusterrole", "gone", LinkRoleRef, "")
+
+	graph.resolveReferences(true)
+	graph.cleanLinks()
+	graph.cleanNodes()
+	graph.cleanLinks()
+
+	nodes := []string{}
+	for _, n := range graph.Nodes {
+		nodes = append(nodes, n.Uid)
+	}
+	sort.Strings(nodes)
+	expectedNodes := []string{missingUid("cm", "config"), "pod"}
+	if !reflect.DeepEqual(nodes, expectedNodes) {
+		t.Errorf("got nodes %v - expected %v", nodes, expectedNodes)
+	}
+
+	expectedProblems := []Problem{{
+		Problem:    ProblemDanglingReference,
+		SourceId:   "pod",
+		SourceKind: "pod",
+		SourceName: "app",
+		TargetKind: "cm",
+		TargetName: "config",
+		Type:       LinkMounts,
+	}}
+	if problems := graph.problems(); !reflect.DeepEqual(problems, expectedProblems) {
+		t.Errorf("got problems %+v - expected %+v", problems, expectedProblems)
+	}
+}
+
+// Problems of nodes that were cleaned out of the graph are not reported.
+func TestProblemsSkipCleanedSources(t *testing.T) {
+	graph := InitGraph()
+	graph.Unresolved = []Reference{{Source: "gone", TargetKind: "secret", TargetName: "s", Type: LinkReferences}}
+
+	if problems := graph.problems(); len(problems) != 0 {
+		t.Errorf("got problems %+v - expected none", problems)
+	}
+}
@@ -0,0 +1,110 @@
+package internal
+
+import (
+	"fmt"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+// ServiceAccounts are linked to their image pull secrets and to their token
+// secrets, both the ones listed in the service account and the ones
+// annotated with its name.
+func serviceAccountLinks(graph *Graph, item unstructured.Unstructured) {
+	uid := string(item.GetUID())
+
+	for _, s := range unstructGetList(item.Object, "secrets") {
+		secret, ok := s.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		if name := unstructGetString(secret, "name"); name != "" {
+			graph.AddReference(uid, "secret", name, LinkToken, "")
+		}
+	}
+
+	for _, name := range pullSecretNames(item.Object) {
+		graph.AddReference(uid, "secret", name, LinkPullSecret, "")
+	}
+
+	for _, node := range graph.Nodes {
+		if node.Kind != "secret" || unstructGetString(node.Object, "type") != "kubernetes.io/service-account-token" {
+			continue
+		}
+		if unstructGetString(node.Object, "metadata", "annotations", "kubernetes.io/service-account.name") == item.GetName() {
+			graph.AddLink(uid, node.Uid, LinkToken, "")
+		}
+	}
+}
+
+// RoleBindings and ClusterRoleBindings are linked to the service accounts of
+// the namespace they bind and to the role they grant. Subjects in other
+// namespaces, users and groups other than the namespace's service accounts
+// are not graphed.
+func roleBindingLinks(graph *Graph, item unstructured.Unstructured) {
+	uid := string(item.GetUID())
+
+	for _, s := range unstructGetList(item.Object, "subjects") {
+		subject, ok := s.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		name := unstructGetString(subject, "name")
+		switch unstructGetString(subject, "kind") {
+		case "ServiceAccount":
+			// service account subjects of RoleBindings default to the
+			// binding's namespace
+			namespace := valueOr(unstructGetString(subject, "namespace"), item.GetNamespace())
+			if namespace != graph.namespace || name == "" {
+				continue
+			}
+			graph.AddReference(uid, "sa", name, LinkSubject, "")
+		case "Group":
+			if name != "system:serviceaccounts" && name != "system:serviceaccounts:"+graph.namespace {
+				continue
+			}
+			for _, node := range graph.Nodes {
+				if node.Kind == "sa" {
+					graph.AddLink(uid, node.Uid, LinkSubject, name)
+				}
+			}
+		}
+	}
+
+	roleRef := unstructGetMap(item.Object, "roleRef")
+	name := unstructGetString(roleRef, "name")
+	if name == "" {
+		return
+	}
+	switch unstructGetString(roleRef, "kind") {
+	case "Role":
+		graph.AddReference(uid, "role", name, LinkRoleRef, "")
+	case "ClusterRole":
+		graph.AddReference(uid, "clusterrole", name, LinkRoleRef, "")
+	}
+}
+
+// roleSummary lists the permissions granted by a Role or ClusterRole, e.g.
+// "get,list pods,services; * secrets".
+func roleSummary(item unstructured.Unstructured) string {
+	rules := []string{}
+	for _, r := range unstructGetList(item.Object, "rules") {
+		rule, ok := r.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		targets := unstructGetStrings(rule, "resources")
+		if len(targets) == 0 {
+			targets = unstructGetStrings(rule, "nonResourceURLs")
+		}
+		if names := unstructGetStrings(rule, "resourceNames"); len(names) > 0 {
+			for i, target := range targets {
+				targets[i] = fmt.Sprintf("%s/%s", target, strings.Join(names, ","))
+			}
+		}
+		rules = append(rules, fmt.Sprintf("%s %s",
+			strings.Join(unstructGetStrings(rule, "verbs"), ","),
+			strings.Join(targets, ",")))
+	}
+	return strings.Join(rules, "; ")
+}
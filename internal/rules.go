@@ -0,0 +1,236 @@
+package internal
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/yaml"
+	"k8s.io/client-go/util/jsonpath"
+)
+
+const (
+	MatchName          = "name"
+	MatchUid           = "uid"
+	MatchLabelSelector = "labelSelector"
+)
+
+// Rule declares a relationship between a source resource and nodes of a
+// target kind: every value found at Path in a source item is matched against
+// the target nodes.
+type Rule struct {
+	Source RuleResource `json:"source"`
+
+	// SourceKind is the node label used if no extractor exists for the
+	// source resource yet - defaults to the resource name.
+	SourceKind string `json:"sourceKind"`
+
+	// Path is a JSONPath expression into the source item, e.g.
+	// .spec.containers[*].envFrom[*].configMapRef.name
+	Path string `json:"path"`
+
+	TargetKind string `json:"targetKind"`
+
+	// Match is one of name (the default), uid or labelSelector.
+	Match string `json:"match"`
+
+	// Type is the relationship type of the resulting links - defaults to
+	// references.
+	Type string `json:"type"`
+
+	// Detail is attached to the resulting links - may be empty.
+	Detail string `json:"detail"`
+}
+
+type RuleResource struct {
+	Group    string `json:"group"`
+	Version  string `json:"version"`
+	Resource string `json:"resource"`
+}
+
+type rulesFile struct {
+	Rules []Rule `json:"rules"`
+}
+
+// LoadRules reads relationship rules from a YAML or JSON file.
+func LoadRules(filename string) ([]Rule, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return nil, fmt.Errorf("could not open rules file: %v", err)
+	}
+	defer f.Close()
+
+	var content rulesFile
+	if err := yaml.NewYAMLOrJSONDecoder(f, 4096).Decode(&content); err != nil {
+		return nil, fmt.Errorf("could not parse rules file %s: %v", filename, err)
+	}
+
+	for i := range content.Rules {
+		if err := content.Rules[i].validate(); err != nil {
+			return nil, fmt.Errorf("invalid rule %d in %s: %v", i+1, filename, err)
+		}
+	}
+
+	return content.Rules, nil
+}
+
+func (r *Rule) validate() error {
+	if r.Source.Version == "" || r.Source.Resource == "" {
+		return fmt.Errorf("source version and resource are mandatory")
+	}
+	if r.TargetKind == "" {
+		return fmt.Errorf("targetKind is mandatory")
+	}
+	if strings.TrimSpace(r.Path) == "" {
+		return fmt.Errorf("path is mandatory")
+	}
+	if r.Match == "" {
+		r.Match = MatchName
+	}
+	if r.Type == "" {
+		r.Type = LinkReferences
+	}
+	if r.Match != MatchName && r.Match != MatchUid && r.Match != MatchLabelSelector {
+		return fmt.Errorf("unknown match type %s", r.Match)
+	}
+	if _, err := r.parsePath(); err != nil {
+		return fmt.Errorf("invalid path %s: %v", r.Path, err)
+	}
+	return nil
+}
+
+func (r Rule) gvr() schema.GroupVersionResource {
+	return schema.GroupVersionResource{Group: r.Source.Group, Version: r.Source.Version, Resource: r.Source.Resource}
+}
+
+// JSONPath keeps state while walking an object, so a new parser is created
+// for every evaluation
+func (r Rule) parsePath() (*jsonpath.JSONPath, error) {
+	jp := jsonpath.New("rule").AllowMissingKeys(true)
+	if err := jp.Parse(fmt.Sprintf("{%s}", r.Path)); err != nil {
+		return nil, err
+	}
+	return jp, nil
+}
+
+// values returns all values found at the rule's path in an object.
+func (r Rule) values(obj map[string]interface{}) []interface{} {
+	jp, err := r.parsePath()
+	if err != nil {
+		return nil
+	}
+	results, err := jp.FindResults(obj)
+	if err != nil {
+		return nil
+	}
+
+	values := []interface{}{}
+	for _, result := range results {
+		for _, v := range result {
+			if !v.IsValid() || !v.CanInterface() {
+				continue
+			}
+			values = append(values, v.Interface())
+		}
+	}
+	return values
+}
+
+func (r Rule) addLinks(graph *Graph, item unstructured.Unstructured) {
+	source := string(item.GetUID())
+
+	for _, value := range r.values(item.Object) {
+		switch r.Match {
+		case MatchName:
+			name, ok := value.(string)
+			if !ok || name == "" {
+				continue
+			}
+			graph.AddReference(source, r.TargetKind, name, r.Type, r.Detail)
+
+		case MatchUid:
+			uid, ok := value.(string)
+			if !ok || uid == "" {
+				continue
+			}
+			graph.AddLink(source, uid, r.Type, r.Detail)
+
+		case MatchLabelSelector:
+			m, ok := value.(map[string]interface{})
+			if !ok {
+				continue
+			}
+			selector, err := unstructSelector(m)
+			if err != nil {
+				continue
+			}
+			for _, uid := range graph.FindBySelector(r.TargetKind, selector) {
+				graph.AddLink(source, uid, r.Type, r.Detail)
+			}
+		}
+	}
+}
+
+// ruleExtractor adds the links of declarative rules to an existing
+// extractor.
+type ruleExtractor struct {
+	Extractor
+	rules []Rule
+}
+
+func (e ruleExtractor) Dependencies() []string {
+	deps := append([]string{}, e.Extractor.Dependencies()...)
+	for _, r := range e.rules {
+		if r.TargetKind != e.Kind() {
+			deps = append(deps, r.TargetKind)
+		}
+	}
+	return deps
+}
+
+func (e ruleExtractor) AddLinks(graph *Graph, item unstructured.Unstructured) {
+	e.Extractor.AddLinks(graph, item)
+	for _, r := range e.rules {
+		r.addLinks(graph, item)
+	}
+}
+
+// RegisterRules attaches rules to the extractors of their source resources.
+// A generic extractor is registered for source resources that do not have
+// one.
+func RegisterRules(rules []Rule) error {
+	bySource := make(map[schema.GroupResource][]Rule)
+	sources := []schema.GroupVersionResource{}
+	for _, r := range rules {
+		gvr := r.gvr()
+		if _, ok := bySource[gvr.GroupResource()]; !ok {
+			sources = append(sources, gvr)
+		}
+		bySource[gvr.GroupResource()] = append(bySource[gvr.GroupResource()], r)
+	}
+
+	for _, gvr := range sources {
+		e := registry.byGVR(gvr)
+		if e == nil {
+			kind := bySource[gvr.GroupResource()][0].SourceKind
+			if kind == "" {
+				kind = gvr.Resource
+			}
+			e = ResourceExtractor{
+				Resource: gvr,
+				Label:    kind,
+			}
+		}
+		RegisterExtractor(ruleExtractor{
+			Extractor: e,
+			rules:     bySource[gvr.GroupResource()],
+		})
+	}
+
+	if _, err := registry.ordered(); err != nil {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,27 @@
+package internal
+
+import "testing"
+
+func TestRuleValidate(t *testing.T) {
+	source := RuleResource{Version: "v1", Resource: "pods"}
+	tests := []struct {
+		name  string
+		rule  Rule
+		valid bool
+	}{
+		{"valid", Rule{Source: source, Path: ".spec.serviceAccountName", TargetKind: "sa"}, true},
+		{"missing version", Rule{Source: RuleResource{Resource: "pods"}, Path: ".spec.serviceAccountName", TargetKind: "sa"}, false},
+		{"missing target kind", Rule{Source: source, Path: ".spec.serviceAccountName"}, false},
+		{"missing path", Rule{Source: source, TargetKind: "sa"}, false},
+		{"blank path", Rule{Source: source, Path: " ", TargetKind: "sa"}, false},
+		{"invalid path", Rule{Source: source, Path: ".spec[", TargetKind: "sa"}, false},
+		{"unknown match", Rule{Source: source, Path: ".spec.serviceAccountName", TargetKind: "sa", Match: "label"}, false},
+	}
+
+	for _, test := range tests {
+		err := test.rule.validate()
+		if valid := err == nil; valid != test.valid {
+			t.Errorf("%s: validate() = %v - expected valid to be %v", test.name, err, test.valid)
+		}
+	}
+}
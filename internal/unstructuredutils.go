@@ -1,6 +1,13 @@
 package internal
 
-import "k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+import (
+	"fmt"
+
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+)
 
 func unstructGetOwners(u unstructured.Unstructured) []string {
 	owners := []string{}
@@ -75,3 +82,86 @@ func unstructGetList(m map[string]interface{}, path ...string) []interface{} {
 	}
 	return list
 }
+
+// unstructGetScalar returns a string, number or boolean field formatted as a
+// string, or "" if the field does not exist.
+func unstructGetScalar(m map[string]interface{}, path ...string) string {
+	if len(path) == 0 {
+		return ""
+	}
+	if len(path) > 1 {
+		m = unstructGetMap(m, path[:len(path)-1]...)
+		if m == nil {
+			return ""
+		}
+	}
+	switch leaf := m[path[len(path)-1]].(type) {
+	case string:
+		return leaf
+	case int64, float64, bool:
+		return fmt.Sprint(leaf)
+	default:
+		return ""
+	}
+}
+
+// unstructGetStrings returns the strings in a list field, skipping items that
+// are not strings.
+func unstructGetStrings(m map[string]interface{}, path ...string) []string {
+	strs := []string{}
+	for _, item := range unstructGetList(m, path...) {
+		if s, ok := item.(string); ok {
+			strs = append(strs, s)
+		}
+	}
+	return strs
+}
+
+func unstructGetLabels(m map[string]interface{}) labels.Set {
+	set := labels.Set{}
+	for k, v := range unstructGetMap(m, "metadata", "labels") {
+		if s, ok := v.(string); ok {
+			set[k] = s
+		}
+	}
+	return set
+}
+
+// unstructLabelSelector converts a LabelSelector to a selector. Unlike
+// unstructSelector, an empty selector matches everything, as it does in
+// PodDisruptionBudgets and NetworkPolicies.
+func unstructLabelSelector(m map[string]interface{}) (labels.Selector, error) {
+	if len(m) == 0 {
+		return labels.Everything(), nil
+	}
+	return unstructSelector(m)
+}
+
+// unstructSelector converts either a LabelSelector (with matchLabels and / or
+// matchExpressions) or a plain map of labels (as used by services) to a
+// selector. An empty selector matches nothing.
+func unstructSelector(m map[string]interface{}) (labels.Selector, error) {
+	if len(m) == 0 {
+		return labels.Nothing(), nil
+	}
+
+	_, hasMatchLabels := m["matchLabels"]
+	_, hasMatchExpressions := m["matchExpressions"]
+	if hasMatchLabels || hasMatchExpressions {
+		var ls v1.LabelSelector
+		if err := runtime.DefaultUnstructuredConverter.FromUnstructured(m, &ls); err != nil {
+			return nil, err
+		}
+		return v1.LabelSelectorAsSelector(&ls)
+	}
+
+	set := labels.Set{}
+	for k, v := range m {
+		s, ok := v.(string)
+		if !ok {
+			continue
+		}
+		set[k] = s
+	}
+	return labels.SelectorFromSet(set), nil
+}
@@ -0,0 +1,62 @@
+package internal
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// classes of errors reported in graph warnings
+const (
+	ErrorClassForbidden    = "forbidden"
+	ErrorClassUnauthorized = "unauthorized"
+	ErrorClassNotFound     = "not-found"
+	ErrorClassTimeout      = "timeout"
+	ErrorClassOther        = "error"
+)
+
+// Warning describes a resource that could not be added to the graph.
+type Warning struct {
+	Kind     string `json:"kind"`
+	Resource string `json:"resource"`
+	Class    string `json:"class"`
+	Message  string `json:"message"`
+}
+
+// notServedError is returned for resources that are not known to the API
+// server.
+type notServedError struct {
+	gr schema.GroupResource
+}
+
+func (e notServedError) Error() string {
+	return fmt.Sprintf("%s is not served by the API server", e.gr)
+}
+
+func newWarning(kind string, gvr schema.GroupVersionResource, err error) Warning {
+	return Warning{
+		Kind:     kind,
+		Resource: gvrString(gvr),
+		Class:    errorClass(err),
+		Message:  err.Error(),
+	}
+}
+
+func errorClass(err error) string {
+	var notServed notServedError
+	switch {
+	case apierrors.IsForbidden(err):
+		return ErrorClassForbidden
+	case apierrors.IsUnauthorized(err):
+		return ErrorClassUnauthorized
+	case apierrors.IsNotFound(err), errors.As(err, &notServed):
+		return ErrorClassNotFound
+	case apierrors.IsTimeout(err), apierrors.IsServerTimeout(err), errors.Is(err, context.DeadlineExceeded):
+		return ErrorClassTimeout
+	default:
+		return ErrorClassOther
+	}
+}
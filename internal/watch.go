@@ -0,0 +1,172 @@
+package internal
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"time"
+)
+
+// changes to a namespace are collected for this long before the graph is
+// rebuilt
+const watchDebounce = 250 * time.Millisecond
+
+const (
+	EventNodeAdded   = "node-added"
+	EventNodeUpdated = "node-updated"
+	EventNodeRemoved = "node-removed"
+	EventLinkAdded   = "link-added"
+	EventLinkRemoved = "link-removed"
+	EventWarnings    = "warnings"
+)
+
+type GraphEvent struct {
+	Type     string    `json:"type"`
+	Node     *Node     `json:"node,omitempty"`
+	Link     *Link     `json:"link,omitempty"`
+	Warnings []Warning `json:"warnings,omitempty"`
+}
+
+// WatchGraph calls initial with the current graph of the namespace, and then
+// calls changes with the differences every time resources in the namespace
+// change. Resources in other namespaces that are part of the graph (e.g. a
+// gateway that a route in the namespace attaches to) are watched as well. It
+// returns when ctx is done or when one of the callbacks returns an error.
+func (kc *KubeClient) WatchGraph(ctx context.Context, namespace string, initial func(Graph) error, changes func([]GraphEvent) error) error {
+	notifications := make(chan struct{}, 1)
+	subscriptions := map[string]func(){
+		namespace: kc.cache.subscribeChannel(namespace, notifications),
+	}
+	defer func() {
+		for _, cancel := range subscriptions {
+			cancel()
+		}
+	}()
+
+	current, err := kc.GetAll(ctx, namespace)
+	if err != nil {
+		return err
+	}
+	kc.followNamespaces(current, namespace, notifications, subscriptions)
+	if err := initial(current); err != nil {
+		return err
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-notifications:
+		}
+
+		// wait for related changes (e.g. a deployment rolling out its pods)
+		// to arrive before rebuilding the graph
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(watchDebounce):
+		}
+
+		next, err := kc.GetAll(ctx, namespace)
+		if err != nil {
+			return err
+		}
+		kc.followNamespaces(next, namespace, notifications, subscriptions)
+		events := diffGraphs(current, next)
+		current = next
+		if len(events) == 0 {
+			continue
+		}
+		if err := changes(events); err != nil {
+			return err
+		}
+	}
+}
+
+// followNamespaces subscribes to the other namespaces that the graph has
+// nodes in, and cancels the subscriptions of namespaces it no longer has
+// nodes in.
+func (kc *KubeClient) followNamespaces(graph Graph, namespace string, notifications chan struct{}, subscriptions map[string]func()) {
+	wanted := map[string]struct{}{namespace: {}}
+	for _, ns := range graph.externalNamespaces() {
+		wanted[ns] = struct{}{}
+		if _, ok := subscriptions[ns]; !ok {
+			subscriptions[ns] = kc.cache.subscribeChannel(ns, notifications)
+		}
+	}
+	for ns, cancel := range subscriptions {
+		if _, ok := wanted[ns]; !ok {
+			cancel()
+			delete(subscriptions, ns)
+		}
+	}
+}
+
+// diffGraphs returns the events needed to turn the old graph into the new
+// graph. Removed links are reported before removed nodes, and added nodes are
+// reported before added links, so that a client never holds a link pointing
+// to a node it does not know about.
+func diffGraphs(old, new Graph) []GraphEvent {
+	events := []GraphEvent{}
+
+	oldNodes := make(map[string]*Node)
+	for _, n := range old.Nodes {
+		oldNodes[n.Uid] = n
+	}
+	newNodes := make(map[string]*Node)
+	for _, n := range new.Nodes {
+		newNodes[n.Uid] = n
+	}
+	oldLinks := make(map[string]struct{})
+	for _, l := range old.Links {
+		oldLinks[l.key()] = struct{}{}
+	}
+	newLinks := make(map[string]struct{})
+	for _, l := range new.Links {
+		newLinks[l.key()] = struct{}{}
+	}
+
+	for i, l := range old.Links {
+		if _, ok := newLinks[l.key()]; !ok {
+			events = append(events, GraphEvent{Type: EventLinkRemoved, Link: &old.Links[i]})
+		}
+	}
+	for _, n := range old.Nodes {
+		if _, ok := newNodes[n.Uid]; !ok {
+			events = append(events, GraphEvent{Type: EventNodeRemoved, Node: n})
+		}
+	}
+	for _, n := range new.Nodes {
+		o, ok := oldNodes[n.Uid]
+		if !ok {
+			events = append(events, GraphEvent{Type: EventNodeAdded, Node: n})
+			continue
+		}
+		if nodeChanged(o, n) {
+			events = append(events, GraphEvent{Type: EventNodeUpdated, Node: n})
+		}
+	}
+	for i, l := range new.Links {
+		if _, ok := oldLinks[l.key()]; !ok {
+			events = append(events, GraphEvent{Type: EventLinkAdded, Link: &new.Links[i]})
+		}
+	}
+	if !reflect.DeepEqual(old.Warnings, new.Warnings) {
+		// the complete list of warnings replaces the previous list
+		events = append(events, GraphEvent{Type: EventWarnings, Warnings: new.Warnings})
+	}
+
+	return events
+}
+
+// links whose detail changed are reported as removed and added again
+func (l Link) key() string {
+	return fmt.Sprintf("%s:%s", linkMapKey(l.Source, l.Target, l.Type), l.Detail)
+}
+
+func nodeChanged(old, new *Node) bool {
+	if old.Kind != new.Kind || old.Name != new.Name || old.Status != new.Status || old.Summary != new.Summary {
+		return true
+	}
+	return unstructGetString(old.Object, "metadata", "resourceVersion") != unstructGetString(new.Object, "metadata", "resourceVersion")
+}
@@ -0,0 +1,120 @@
+package internal
+
+import (
+	"reflect"
+	"testing"
+)
+
+func testObject(resourceVersion string) map[string]interface{} {
+	return map[string]interface{}{
+		"metadata": map[string]interface{}{"resourceVersion": resourceVersion},
+	}
+}
+
+// eventString describes an event as type:uid or type:source->target(detail)
+func eventString(e GraphEvent) string {
+	switch {
+	case e.Node != nil:
+		return e.Type + ":" + e.Node.Uid
+	case e.Link != nil:
+		return e.Type + ":" + e.Link.Source + "->" + e.Link.Target + "(" + e.Link.Detail + ")"
+	default:
+		return e.Type
+	}
+}
+
+func TestDiffGraphs(t *testing.T) {
+	old := InitGraph()
+	old.AddNode("deploy", "deploy", "app", testObject("1"))
+	old.AddNode("rs", "rs", "app-1", testObject("1"))
+	old.AddNode("pod", "pod", "app-1-a", testObject("1"))
+	old.AddNode("cm", "cm", "config", testObject("1"))
+	old.AddLink("deploy", "rs", LinkOwns, "")
+	old.AddLink("rs", "pod", LinkOwns, "")
+	old.AddLink("pod", "cm", LinkMounts, "/etc/a")
+
+	new := InitGraph()
+	new.AddNode("deploy", "deploy", "app", testObject("2"))
+	new.AddNode("rs", "rs", "app-1", testObject("1"))
+	new.AddNode("pod2", "pod", "app-1-b", testObject("1"))
+	new.AddNode("cm", "cm", "config", testObject("1"))
+	new.AddLink("deploy", "rs", LinkOwns, "")
+	new.AddLink("rs", "pod2", LinkOwns, "")
+	new.AddLink("pod2", "cm", LinkMounts, "/etc/b")
+	new.addWarning(Warning{Kind: "secret", Class: "forbidden"})
+
+	got := []string{}
+	for _, e := range diffGraphs(*old, *new) {
+		got = append(got, eventString(e))
+	}
+	expected := []string{
+		EventLinkRemoved + ":rs->pod()",
+		EventLinkRemoved + ":pod->cm(/etc/a)",
+		EventNodeRemoved + ":pod",
+		EventNodeUpdated + ":deploy",
+		EventNodeAdded + ":pod2",
+		EventLinkAdded + ":rs->pod2()",
+		EventLinkAdded + ":pod2->cm(/etc/b)",
+		EventWarnings,
+	}
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("got events %v - expected %v", got, expected)
+	}
+
+	if events := diffGraphs(*new, *new); len(events) != 0 {
+		t.Errorf("got %d events for an unchanged graph - expected none", len(events))
+	}
+}
+
+func TestDiffGraphsLinkDetail(t *testing.T) {
+	old := InitGraph()
+	old.AddNode("pod", "pod", "app", nil)
+	old.AddNode("cm", "cm", "config", nil)
+	old.AddLink("pod", "cm", LinkMounts, "/etc/a")
+
+	new := InitGraph()
+	new.AddNode("pod", "pod", "app", nil)
+	new.AddNode("cm", "cm", "config", nil)
+	new.AddLink("pod", "cm", LinkMounts, "/etc/b")
+
+	got := []string{}
+	for _, e := range diffGraphs(*old, *new) {
+		got = append(got, eventString(e))
+	}
+	expected := []string{
+		EventLinkRemoved + ":pod->cm(/etc/a)",
+		EventLinkAdded + ":pod->cm(/etc/b)",
+	}
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("got events %v - expected %v", got, expected)
+	}
+}
+
+func TestNodeChanged(t *testing.T) {
+	base := Node{Uid: "a", Kind: "pod", Name: "app", Object: testObject("1")}
+
+	tests := []struct {
+		name    string
+		change  func(n *Node)
+		changed bool
+	}{
+		{"unchanged", func(n *Node) {}, false},
+		{"kind", func(n *Node) { n.Kind = "deploy" }, true},
+		{"name", func(n *Node) { n.Name = "other" }, true},
+		{"status", func(n *Node) { n.Status = NodeStatusMissing }, true},
+		{"summary", func(n *Node) { n.Summary = "1/1 ready" }, true},
+		{"resource version", func(n *Node) { n.Object = testObject("2") }, true},
+		{"other fields", func(n *Node) {
+			n.Object = testObject("1")
+			n.Object["status"] = map[string]interface{}{"phase": "Running"}
+		}, false},
+	}
+
+	for _, test := range tests {
+		n := base
+		test.change(&n)
+		if changed := nodeChanged(&base, &n); changed != test.changed {
+			t.Errorf("%s: nodeChanged() = %v - expected %v", test.name, changed, test.changed)
+		}
+	}
+}
@@ -0,0 +1,304 @@
+// Package server serves the graph web application. Programs that graph
+// additional resource kinds register their extractors and call Run from their
+// main function.
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/kwkoo/configparser"
+	"github.com/kwkoo/k8s-graph/docroot"
+	"github.com/kwkoo/k8s-graph/internal"
+)
+
+var client *internal.KubeClient
+
+// watch streams are long-running requests that http.Server.Shutdown does not
+// wait for - they are stopped when this context is cancelled
+var streams, stopStreams = context.WithCancel(context.Background())
+
+func projectHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	projects, err := client.GetProjects(context.Background())
+	if err != nil {
+		writeError(w, err.Error())
+		return
+	}
+	writeJSON(w, projects)
+}
+
+func graphHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	slash := strings.LastIndex(r.URL.Path, "/")
+	if slash == -1 {
+		writeError(w, "invalid URI - expecting namespace name")
+		return
+	}
+	namespace := r.URL.Path[slash+1:]
+	graph, err := client.GetAll(r.Context(), namespace)
+	if err != nil {
+		writeError(w, err.Error())
+		return
+	}
+	writeJSON(w, graph)
+}
+
+func problemsHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	slash := strings.LastIndex(r.URL.Path, "/")
+	if slash == -1 {
+		writeError(w, "invalid URI - expecting namespace name")
+		return
+	}
+	namespace := r.URL.Path[slash+1:]
+	problems, err := client.GetProblems(r.Context(), namespace)
+	if err != nil {
+		writeError(w, err.Error())
+		return
+	}
+	writeJSON(w, problems)
+}
+
+// comments are sent on idle watch streams this often
+const watchKeepalive = 15 * time.Second
+
+func watchHandler(w http.ResponseWriter, r *http.Request) {
+	slash := strings.LastIndex(r.URL.Path, "/")
+	if slash == -1 {
+		w.Header().Set("Content-Type", "application/json")
+		writeError(w, "invalid URI - expecting namespace name")
+		return
+	}
+	namespace := r.URL.Path[slash+1:]
+
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		w.Header().Set("Content-Type", "application/json")
+		writeError(w, "streaming is not supported")
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+
+	// events and keepalives are written from different goroutines
+	var mutex sync.Mutex
+	send := func(write func()) {
+		mutex.Lock()
+		defer mutex.Unlock()
+		write()
+		flusher.Flush()
+	}
+
+	// keep proxies from closing the stream while the namespace is idle, and
+	// end the stream when the server shuts down
+	ctx, cancel := context.WithCancel(r.Context())
+	keepalives := sync.WaitGroup{}
+	keepalives.Add(1)
+	go func() {
+		defer keepalives.Done()
+		ticker := time.NewTicker(watchKeepalive)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-streams.Done():
+				cancel()
+				return
+			case <-ticker.C:
+				send(func() { fmt.Fprint(w, ": keepalive\n\n") })
+			}
+		}
+	}()
+
+	err := client.WatchGraph(ctx, namespace,
+		func(graph internal.Graph) error {
+			send(func() { writeEvent(w, "graph", graph) })
+			return nil
+		},
+		func(events []internal.GraphEvent) error {
+			// a batch is sent as a single event so that the client only
+			// redraws the graph once per change
+			send(func() { writeEvent(w, "changes", events) })
+			return nil
+		})
+	cancel()
+	keepalives.Wait()
+	if err != nil {
+		send(func() {
+			writeEvent(w, "graph", struct {
+				Err string `json:"error"`
+			}{
+				Err: err.Error(),
+			})
+		})
+	}
+}
+
+func cacheHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	writeJSON(w, client.GetCacheStatus())
+}
+
+// Run reads the configuration from the command line and the environment,
+// and serves the web application until the process is interrupted.
+func Run() {
+	config := struct {
+		Port             int    `default:"8080" usage:"HTTP listener port"`
+		Docroot          string `usage:"HTML document root - will use the embedded docroot if not specified"`
+		MasterURL        string `usage:"Kubernetes master URL - will use the in-cluster config if not specified"`
+		Kubeconfig       string `usage:"Path to the kubeconfig file - will use the in-cluster config if not specified"`
+		CacheResync      int    `default:"600" usage:"Informer cache resync period in seconds"`
+		CacheIdleTimeout int    `default:"1800" usage:"Stop watching namespaces that have not been requested for this many seconds - 0 to never evict"`
+		CacheClusterWide bool   `usage:"Watch resources in all namespaces instead of starting informers per namespace"`
+		Rules            string `usage:"Path to a YAML or JSON file with additional relationship rules"`
+		Discovery        bool   `usage:"Graph every listable namespaced resource (including CRDs) found through the discovery API"`
+		DiscoveryInclude string `usage:"Comma-separated list of resources (resource.group) or groups to graph when discovery is enabled - all if not specified"`
+		DiscoveryExclude string `default:"events,events.events.k8s.io,controllerrevisions.apps,leases.coordination.k8s.io,endpoints" usage:"Comma-separated list of resources (resource.group) or groups to skip when discovery is enabled"`
+		DiscoveryRefresh int    `default:"600" usage:"How often to rediscover the resources and API versions served by the cluster in seconds - 0 to only discover them at startup"`
+		FetchWorkers     int    `default:"4" usage:"Number of resource types fetched concurrently when building a graph"`
+		FetchTimeout     int    `default:"30" usage:"Deadline for fetching the resources of a graph in seconds - 0 for no deadline"`
+		MissingNodes     bool   `usage:"Add placeholder nodes for referenced resources that do not exist"`
+		NetworkTraffic   bool   `usage:"Link pods that network policies allow to talk to each other"`
+	}{}
+	if err := configparser.Parse(&config); err != nil {
+		log.Fatal(err)
+	}
+
+	var filesystem http.FileSystem
+	if len(config.Docroot) > 0 {
+		log.Printf("using %s in the file system as the document root", config.Docroot)
+		filesystem = http.Dir(config.Docroot)
+	} else {
+		log.Print("using the embedded filesystem as the docroot")
+		filesystem = http.FS(docroot.Content)
+	}
+
+	fileServer := http.FileServer(filesystem).ServeHTTP
+
+	if len(config.Rules) > 0 {
+		rules, err := internal.LoadRules(config.Rules)
+		if err != nil {
+			log.Fatal(err)
+		}
+		if err := internal.RegisterRules(rules); err != nil {
+			log.Fatal(err)
+		}
+		log.Printf("loaded %d relationship rules from %s", len(rules), config.Rules)
+	}
+
+	var err error
+	client, err = internal.InitKubeClient(config.MasterURL, config.Kubeconfig, internal.KubeClientOptions{
+		Cache: internal.CacheOptions{
+			Resync:      time.Duration(config.CacheResync) * time.Second,
+			IdleTimeout: time.Duration(config.CacheIdleTimeout) * time.Second,
+			ClusterWide: config.CacheClusterWide,
+		},
+		Discovery: internal.DiscoveryOptions{
+			Enabled: config.Discovery,
+			Include: splitList(config.DiscoveryInclude),
+			Exclude: splitList(config.DiscoveryExclude),
+			Refresh: time.Duration(config.DiscoveryRefresh) * time.Second,
+		},
+		Fetch: internal.FetchOptions{
+			Workers: config.FetchWorkers,
+			Timeout: time.Duration(config.FetchTimeout) * time.Second,
+		},
+		MissingNodes:   config.MissingNodes,
+		NetworkTraffic: config.NetworkTraffic,
+	})
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	// Setup signal handling.
+	shutdown := make(chan os.Signal, 1)
+	signal.Notify(shutdown, os.Interrupt, syscall.SIGTERM)
+
+	var wg sync.WaitGroup
+	server := &http.Server{
+		Addr: fmt.Sprintf(":%d", config.Port),
+	}
+	server.RegisterOnShutdown(stopStreams)
+	go func() {
+		log.Printf("listening on port %v", config.Port)
+		http.HandleFunc("/api/projects", projectHandler)
+		http.HandleFunc("/api/graph/", graphHandler)
+		http.HandleFunc("/api/watch/", watchHandler)
+		http.HandleFunc("/api/problems/", problemsHandler)
+		http.HandleFunc("/api/cache", cacheHandler)
+		http.HandleFunc("/", fileServer)
+		wg.Add(1)
+		defer wg.Done()
+		if err := server.ListenAndServe(); err != nil {
+			if err == http.ErrServerClosed {
+				log.Print("web server graceful shutdown")
+				return
+			}
+			log.Fatal(err)
+		}
+	}()
+
+	// Wait for SIGINT
+	<-shutdown
+	log.Print("initiating web server shutdown...")
+	signal.Reset(os.Interrupt, syscall.SIGTERM)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	server.Shutdown(ctx)
+
+	wg.Wait()
+	client.Close()
+	log.Print("shutdown successful")
+}
+
+func writeJSON(w io.Writer, data interface{}) {
+	enc := json.NewEncoder(w)
+	if err := enc.Encode(data); err != nil {
+		log.Printf("error converting data to JSON: %v", err)
+	}
+}
+
+// splitList splits a comma-separated list, dropping empty entries.
+func splitList(s string) []string {
+	list := []string{}
+	for _, entry := range strings.Split(s, ",") {
+		entry = strings.TrimSpace(entry)
+		if len(entry) > 0 {
+			list = append(list, entry)
+		}
+	}
+	return list
+}
+
+// writeEvent writes a single Server-Sent Event with a JSON payload.
+func writeEvent(w io.Writer, event string, data interface{}) {
+	fmt.Fprintf(w, "event: %s\ndata: ", event)
+	writeJSON(w, data)
+	fmt.Fprint(w, "\n")
+}
+
+func writeError(w io.Writer, message string) {
+	writeJSON(w, struct {
+		Err string `json:"error"`
+	}{
+		Err: message,
+	})
+}